@@ -25,13 +25,33 @@ func (s *State) HandleAddStone(evt *EventJSON) (*Frame, error) {
 		return nil, nil
 	}
 
+	// during free placement, stones go on the root as setup
+	if s.FreeHandicap > 0 && s.Current == s.Root {
+		diff, err := s.PlaceHandicapStone(c)
+		if err != nil || diff == nil {
+			return nil, err
+		}
+		marks := s.GenerateMarks()
+		return &Frame{Type: DiffFrame, Diff: diff, Marks: marks, Metadata: s.GenerateMetadata()}, nil
+	}
+
 	col := Color(evt.Color)
 
-	// do nothing on a suicide move
-	if !s.Board.Legal(c, col) {
+	// do nothing on an occupied point
+	if s.Board.Get(c) != NoColor {
 		return nil, nil
 	}
 
+	// suicide gets rejected with a reason, like ko
+	if !s.Board.Legal(c, col) {
+		return nil, fmt.Errorf("suicide at %s is not allowed under %s rules", c.ToLetters(), s.Board.Rules)
+	}
+
+	// ko moves get rejected with a reason
+	if err := s.KoViolation(c, col); err != nil {
+		return nil, err
+	}
+
 	fields := make(map[string][]string)
 	key := "B"
 	if col == White {
@@ -43,8 +63,8 @@ func (s *State) HandleAddStone(evt *EventJSON) (*Frame, error) {
 
 	marks := s.GenerateMarks()
 
-	explorer := s.Root.FillGrid(s.Current.Index)
-	return &Frame{DiffFrame, diff, marks, explorer, nil, nil}, nil
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Diff: diff, Marks: marks, Explorer: explorer}, nil
 }
 
 func (s *State) HandlePass(evt *EventJSON) (*Frame, error) {
@@ -57,8 +77,8 @@ func (s *State) HandlePass(evt *EventJSON) (*Frame, error) {
 	fields[key] = []string{""}
 	s.AddPassNode(Color(evt.Color), fields, -1)
 
-	explorer := s.Root.FillGrid(s.Current.Index)
-	return &Frame{DiffFrame, nil, nil, explorer, nil, nil}, nil
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Explorer: explorer}, nil
 }
 
 
@@ -78,8 +98,8 @@ func (s *State) HandleRemoveStone(evt *EventJSON) (*Frame, error) {
 	fields["AE"] = []string{c.ToLetters()}
 	diff := s.AddFieldNode(fields, -1)
 
-	explorer := s.Root.FillGrid(s.Current.Index)
-	return &Frame{DiffFrame, diff, nil, explorer, nil, nil}, nil
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Diff: diff, Explorer: explorer}, nil
 }
 
 func (s *State) HandleAddTriangle(evt *EventJSON) (*Frame, error) {
@@ -172,57 +192,58 @@ func (s *State) HandleRemoveMark(evt *EventJSON) (*Frame, error) {
 			}
 		}
 	}
+	s.Current.Uncredit(l, "TR", "SQ", "LB")
 	return nil, nil
 }
 
 func (s *State) HandleLeft() (*Frame, error) {
 	diff := s.Left()
 	marks := s.GenerateMarks()
-	explorer := s.Root.FillGrid(s.Current.Index)
+	explorer := s.Explorer()
 
 	// left doesn't change the preferred nodes and edges
 	explorer.Nodes = nil
 	explorer.Edges = nil
 	explorer.PreferredNodes = nil
 	comments := s.GenerateComments()
-	return &Frame{DiffFrame, diff, marks, explorer, comments, nil}, nil
+	return &Frame{Type: DiffFrame, Diff: diff, Marks: marks, Explorer: explorer, Comments: comments}, nil
 }
 
 func (s *State) HandleRight() (*Frame, error) {
 	diff := s.Right()
 	marks := s.GenerateMarks()
-	explorer := s.Root.FillGrid(s.Current.Index)
+	explorer := s.Explorer()
 
 	// right doesn't change the preferred nodes and edges
 	explorer.Nodes = nil
 	explorer.Edges = nil
 	explorer.PreferredNodes = nil
 	comments := s.GenerateComments()
-	return &Frame{DiffFrame, diff, marks, explorer, comments, nil}, nil
+	return &Frame{Type: DiffFrame, Diff: diff, Marks: marks, Explorer: explorer, Comments: comments}, nil
 }
 
 func (s *State) HandleUp() (*Frame, error) {
 	s.Up()
-	explorer := s.Root.FillGrid(s.Current.Index)
+	explorer := s.Explorer()
 	explorer.Nodes = nil
 	explorer.Edges = nil
 
 	// for the current mark
 	marks := s.GenerateMarks()
 
-	return &Frame{DiffFrame, nil, marks, explorer, nil, nil}, nil
+	return &Frame{Type: DiffFrame, Marks: marks, Explorer: explorer}, nil
 }
 
 func (s *State) HandleDown() (*Frame, error) {
 	s.Down()
-	explorer := s.Root.FillGrid(s.Current.Index)
+	explorer := s.Explorer()
 	explorer.Nodes = nil
 	explorer.Edges = nil
 
 	// for the current mark
 	marks := s.GenerateMarks()
 
-	return &Frame{DiffFrame, nil, marks, explorer, nil, nil}, nil
+	return &Frame{Type: DiffFrame, Marks: marks, Explorer: explorer}, nil
 }
 
 func (s *State) HandleRewind() (*Frame, error) {
@@ -241,6 +262,18 @@ func (s *State) HandleGotoGrid(evt *EventJSON) (*Frame, error) {
 	return s.GenerateFullFrame(false), nil
 }
 
+func (s *State) HandleGotoTransposition(evt *EventJSON) (*Frame, error) {
+	// the value is optional, without one we cycle through them
+	index := -1
+	if f, ok := evt.Value.(float64); ok {
+		index = int(f)
+	}
+	if err := s.GotoTransposition(index); err != nil {
+		return nil, err
+	}
+	return s.GenerateFullFrame(false), nil
+}
+
 func (s *State) HandleGotoCoord(evt *EventJSON) (*Frame, error) {
 	coords := make([]int, 0)
 	// coerce the value to an array
@@ -262,6 +295,72 @@ func (s *State) HandleComment(evt *EventJSON) (*Frame, error) {
 	return nil, nil
 }
 
+func (s *State) HandleNodeName(evt *EventJSON) (*Frame, error) {
+	if evt.Event == "set_node_name" {
+		name, ok := evt.Value.(string)
+		if !ok {
+			return nil, fmt.Errorf("invalid node name")
+		}
+		s.SetNodeName(name)
+	} else {
+		s.ClearNodeName()
+	}
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Explorer: explorer}, nil
+}
+
+func (s *State) HandleAnnotate(evt *EventJSON) (*Frame, error) {
+	var err error
+	if evt.Event == "clear_annotation" {
+		// no value clears them all
+		key, _ := evt.Value.(string)
+		err = s.ClearAnnotation(key)
+	} else {
+		m, ok := evt.Value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("invalid annotation")
+		}
+		key, _ := m["key"].(string)
+		value, ok := m["value"].(float64)
+		if !ok {
+			value = 1
+		}
+		err = s.Annotate(key, value)
+	}
+	if err != nil {
+		return nil, err
+	}
+	marks := s.GenerateMarks()
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Marks: marks, Explorer: explorer}, nil
+}
+
+func (s *State) HandleCompare(evt *EventJSON) (*Frame, error) {
+	// either two node indexes, or one to compare with the current node
+	indexes := []int{s.Current.Index}
+	switch v := evt.Value.(type) {
+	case float64:
+		indexes = append(indexes, int(v))
+	case []interface{}:
+		indexes = []int{}
+		for _, i := range v {
+			f, ok := i.(float64)
+			if !ok {
+				return nil, fmt.Errorf("invalid node index")
+			}
+			indexes = append(indexes, int(f))
+		}
+	}
+	if len(indexes) != 2 {
+		return nil, fmt.Errorf("compare needs two nodes")
+	}
+	cmp, err := s.Compare(indexes[0], indexes[1])
+	if err != nil {
+		return nil, err
+	}
+	return &Frame{Type: DiffFrame, Comparison: cmp}, nil
+}
+
 func (s *State) HandleDraw(evt *EventJSON) (*Frame, error) {
 	vals := evt.Value.([]interface{})
 	var x0 float64
@@ -290,15 +389,94 @@ func (s *State) HandleDraw(evt *EventJSON) (*Frame, error) {
 
 func (s *State) HandleErasePen() (*Frame, error) {
 	delete(s.Current.Fields, "PX")
+	s.Current.UncreditAll("PX")
 	return nil, nil
 }
 
 func (s *State) HandleCut(evt *EventJSON) (*Frame, error) {
 	diff := s.Cut()
 	marks := s.GenerateMarks()
-	explorer := s.Root.FillGrid(s.Current.Index)
+	explorer := s.Explorer()
 	comments := s.GenerateComments()
-	return &Frame{DiffFrame, diff, marks, explorer, comments, nil}, nil
+	return &Frame{Type: DiffFrame, Diff: diff, Marks: marks, Explorer: explorer, Comments: comments}, nil
+}
+
+func (s *State) HandleEdit(evt *EventJSON) (*Frame, error) {
+	var report *ReplayReport
+	var err error
+	switch evt.Event {
+	case "delete_node":
+		// the value is an optional node index, defaulting to the current node
+		index := s.Current.Index
+		if f, ok := evt.Value.(float64); ok {
+			index = int(f)
+		}
+		report, err = s.DeleteNode(index)
+	case "insert_pass":
+		report, err = s.InsertNode(nil, Color(evt.Color))
+	case "insert_move", "replace_move":
+		c, cerr := InterfaceToCoord(evt.Value)
+		if cerr != nil {
+			return nil, cerr
+		}
+		if c.X >= s.Size || c.Y >= s.Size || c.X < 0 || c.Y < 0 {
+			return nil, nil
+		}
+		if evt.Event == "insert_move" {
+			report, err = s.InsertNode(c, Color(evt.Color))
+		} else {
+			report, err = s.ReplaceMove(c)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+	frame := s.GenerateFullFrame(true)
+	frame.Replay = report
+	return frame, nil
+}
+
+func (s *State) HandleCollapse(evt *EventJSON) (*Frame, error) {
+	if evt.Event == "expand_all" {
+		s.ExpandAll()
+	} else {
+		// the value is an optional node index, defaulting to the current node
+		index := s.Current.Index
+		if f, ok := evt.Value.(float64); ok {
+			index = int(f)
+		}
+		if err := s.Collapse(index, evt.Event == "collapse"); err != nil {
+			return nil, err
+		}
+	}
+	return s.GenerateFullFrame(true), nil
+}
+
+func (s *State) HandleReorder(evt *EventJSON) (*Frame, error) {
+	// the value is an optional node index, defaulting to the current node
+	index := s.Current.Index
+	if f, ok := evt.Value.(float64); ok {
+		index = int(f)
+	}
+
+	var err error
+	switch evt.Event {
+	case "promote_variation":
+		err = s.PromoteVariation(index)
+	case "move_branch_up":
+		err = s.MoveBranch(index, -1)
+	case "move_branch_down":
+		err = s.MoveBranch(index, 1)
+	case "make_mainline":
+		err = s.MakeMainline(index)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	marks := s.GenerateMarks()
+	explorer := s.Explorer()
+	return &Frame{Type: DiffFrame, Marks: marks, Explorer: explorer}, nil
 }
 
 func (s *State) HandleCopy() (*Frame, error) {
@@ -312,22 +490,60 @@ func (s *State) HandleClipboard() (*Frame, error) {
 	}
 
 	// keep a copy of the clipboard unaltered
-	clipboard := s.Clipboard.Copy()
+	report := s.Paste(s.Clipboard.Copy())
+
+	explorer := s.Explorer()
+	marks := s.GenerateMarks()
+	return &Frame{Type: DiffFrame, Marks: marks, Explorer: explorer, Replay: report}, nil
+}
+
+func (s *State) HandleStartScoring() (*Frame, error) {
+	s.StartScoring()
+	return s.GenerateFullFrame(false), nil
+}
+
+func (s *State) HandleToggleDead(evt *EventJSON) (*Frame, error) {
+	c, err := InterfaceToCoord(evt.Value)
+	if err != nil {
+		return nil, err
+	}
+
+	x := c.X
+	y := c.Y
+	if x >= s.Size || y >= s.Size || x < 0 || y < 0 {
+		return nil, nil
+	}
+
+	err = s.ToggleDead(c)
+	if err != nil {
+		return nil, err
+	}
+	return s.GenerateFullFrame(false), nil
+}
 
-	// first give the copy indexes
-	Fmap(func(n *TreeNode) {
-		i := s.GetNextIndex()
-		n.Index = i
-		s.Nodes[i] = n
-	}, clipboard)
+func (s *State) HandleConfirmScore() (*Frame, error) {
+	score, err := s.ConfirmScore()
+	if err != nil {
+		return nil, err
+	}
+	frame := s.GenerateFullFrame(false)
+	frame.Score = score
+	return frame, nil
+}
 
-	// add the clipboard branch to the children of the current node
-	s.Current.Down = append(s.Current.Down, clipboard)
+func (s *State) HandleCancelScoring() (*Frame, error) {
+	s.Scoring = nil
+	return s.GenerateFullFrame(false), nil
+}
 
-	// set the current node to be the parent of the clipboard branch
-	clipboard.Up = s.Current
+func (s *State) HandleEstimateScore() (*Frame, error) {
+	marks := s.GenerateMarks()
+	estimate := s.EstimateScore()
+	return &Frame{Type: DiffFrame, Marks: marks, Estimate: estimate}, nil
+}
 
-	explorer := s.Root.FillGrid(s.Current.Index)
+func (s *State) HandleToggleTactics() (*Frame, error) {
+	s.Tactics = !s.Tactics
 	marks := s.GenerateMarks()
-	return &Frame{DiffFrame, nil, marks, explorer, nil, nil}, nil
+	return &Frame{Type: DiffFrame, Marks: marks}, nil
 }
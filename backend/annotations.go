@@ -0,0 +1,177 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// position annotations: good for black, good for white, even, unclear
+var positionKeys = []string{"GB", "GW", "DM", "UC"}
+
+// move annotations: tesuji, bad move, doubtful, interesting
+var moveKeys = []string{"TE", "BM", "DO", "IT"}
+
+// how annotations look on the explorer
+var annotationSymbols = map[string][2]string{
+	"GB": {"B", "BB"},
+	"GW": {"W", "WW"},
+	"DM": {"=", "=="},
+	"UC": {"~", "~~"},
+	"HO": {"*", "**"},
+	"TE": {"!", "!!"},
+	"BM": {"?", "??"},
+	"DO": {"?!", "?!"},
+	"IT": {"!?", "!?"},
+}
+
+// Annotations are a node's SGF annotation properties
+// Position and Move are property names ("" if not there) and the
+// emphasis is 1 (normal) or 2 (very), as are Hotspot's values
+type Annotations struct {
+	Position         string   `json:"position"`
+	PositionEmphasis int      `json:"position_emphasis"`
+	Move             string   `json:"move"`
+	MoveEmphasis     int      `json:"move_emphasis"`
+	Hotspot          int      `json:"hotspot"`
+	Value            *float64 `json:"value"`
+}
+
+// emphasis reads an SGF double, which is 1 unless it says 2
+func emphasis(values []string) int {
+	if len(values) > 0 && values[0] == "2" {
+		return 2
+	}
+	return 1
+}
+
+// Annotations reads the node's annotations, or nil if it has none
+func (n *TreeNode) Annotations() *Annotations {
+	a := &Annotations{}
+	found := false
+	for _, key := range positionKeys {
+		if values, ok := n.Fields[key]; ok {
+			a.Position = key
+			a.PositionEmphasis = emphasis(values)
+			found = true
+			break
+		}
+	}
+	for _, key := range moveKeys {
+		if values, ok := n.Fields[key]; ok {
+			a.Move = key
+			a.MoveEmphasis = emphasis(values)
+			found = true
+			break
+		}
+	}
+	if values, ok := n.Fields["HO"]; ok {
+		a.Hotspot = emphasis(values)
+		found = true
+	}
+	if values, ok := n.Fields["V"]; ok && len(values) > 0 {
+		if v, err := strconv.ParseFloat(values[0], 64); err == nil {
+			a.Value = &v
+			found = true
+		}
+	}
+	if !found {
+		return nil
+	}
+	return a
+}
+
+// Symbol is a short way to show the annotations on the explorer
+func (a *Annotations) Symbol() string {
+	if a == nil {
+		return ""
+	}
+	symbol := ""
+	if a.Move != "" {
+		symbol += annotationSymbols[a.Move][a.MoveEmphasis-1]
+	}
+	if a.Position != "" {
+		symbol += annotationSymbols[a.Position][a.PositionEmphasis-1]
+	}
+	if a.Hotspot != 0 {
+		symbol += annotationSymbols["HO"][a.Hotspot-1]
+	}
+	return symbol
+}
+
+func inKeys(key string, keys []string) bool {
+	for _, k := range keys {
+		if k == key {
+			return true
+		}
+	}
+	return false
+}
+
+func isAnnotation(key string) bool {
+	return inKeys(key, positionKeys) || inKeys(key, moveKeys) || key == "HO" || key == "V"
+}
+
+// Annotate sets an annotation on the current node, replacing any other
+// of the same kind (a move can't be both a tesuji and a bad move)
+// value is the emphasis (1 or 2) or, for V, the estimated score
+func (s *State) Annotate(key string, value float64) error {
+	n := s.Current
+	if !isAnnotation(key) {
+		return fmt.Errorf("unknown annotation %s", key)
+	}
+	// passes are moves too
+	if inKeys(key, moveKeys) && n.Color != Black && n.Color != White {
+		return fmt.Errorf("%s only goes on moves", key)
+	}
+
+	v := ""
+	switch key {
+	case "V":
+		v = strconv.FormatFloat(value, 'f', -1, 64)
+	case "DO", "IT":
+		// these don't take a value
+	default:
+		if value != 1 && value != 2 {
+			return fmt.Errorf("%s takes 1 or 2", key)
+		}
+		v = strconv.Itoa(int(value))
+	}
+
+	for _, keys := range [][]string{positionKeys, moveKeys} {
+		if inKeys(key, keys) {
+			for _, k := range keys {
+				delete(n.Fields, k)
+			}
+		}
+	}
+	n.Fields[key] = []string{v}
+	return nil
+}
+
+// ClearAnnotation takes an annotation off the current node,
+// or all of them if key is ""
+func (s *State) ClearAnnotation(key string) error {
+	if key == "" {
+		for k := range s.Current.Fields {
+			if isAnnotation(k) {
+				delete(s.Current.Fields, k)
+			}
+		}
+		return nil
+	}
+	if !isAnnotation(key) {
+		return fmt.Errorf("unknown annotation %s", key)
+	}
+	delete(s.Current.Fields, key)
+	return nil
+}
@@ -48,6 +48,23 @@ type Frame struct {
 	Explorer *Explorer `json:"explorer"`
 	Comments []string  `json:"comments"`
 	Metadata *Metadata `json:"metadata"`
+	Score    *Score    `json:"score"`
+	Estimate *Estimate `json:"estimate"`
+	Captures *Captures `json:"captures"`
+	// other nodes with the same position as the current one
+	Transpositions []int `json:"transpositions"`
+	// named nodes in the tree
+	Bookmarks []*Bookmark `json:"bookmarks"`
+	// how two nodes differ, when asked for
+	Comparison *Comparison `json:"comparison"`
+	// who added what to the current node
+	Credits []*Credit `json:"credits"`
+	// who won an atari-go game
+	Winner Color `json:"winner"`
+	// the color to play at the current node
+	Turn Color `json:"turn"`
+	// what a paste or edit dropped, only sent to whoever did it
+	Replay *ReplayReport `json:"-"`
 }
 
 type Marks struct {
@@ -56,6 +73,20 @@ type Marks struct {
 	Triangles []*Coord `json:"triangles"`
 	Labels    []*Label `json:"labels"`
 	Pens      []*Pen   `json:"pens"`
+
+	BlackTerritory []*Coord `json:"black_territory"`
+	WhiteTerritory []*Coord `json:"white_territory"`
+	Dead           []*Coord `json:"dead"`
+
+	// the tactics overlay
+	Atari          []*Coord `json:"atari"`
+	Ladders        []*Coord `json:"ladders"`
+	LadderBreakers []*Coord `json:"ladder_breakers"`
+	Nets           []*Coord `json:"nets"`
+	Snapbacks      []*Coord `json:"snapbacks"`
+
+	// SGF annotations (GB, TE, V...) on the current node
+	Annotations *Annotations `json:"annotations"`
 }
 
 type Label struct {
@@ -72,8 +103,9 @@ type Pen struct {
 }
 
 type Metadata struct {
-	Size   int                 `json:"size"`
-	Fields map[string][]string `json:"fields"`
+	Size     int                 `json:"size"`
+	Fields   map[string][]string `json:"fields"`
+	Captures *Captures           `json:"captures"`
 }
 
 func Opposite(c Color) Color {
@@ -218,21 +250,104 @@ func NewGroup(coords CoordSet, libs CoordSet, col Color) *Group {
 	}
 }
 
+/*
+the board keeps its stones in one flat slice (point p is y*size+x),
+and Points holds row views into it so Points[y][x] still works
+
+connected stones of one color form a chain, and the chains are kept
+up to date as stones come and go:
+- head[p] is the point that represents p's chain (-1 if p is empty)
+- next[p] links the points of a chain into a circular list
+- libs[h] and count[h] are the pseudo-liberties and size of the chain at h
+
+a pseudo-liberty is a (stone, empty neighbor) pair, so an empty point
+touching two stones of a chain counts twice. that's all we need:
+a chain has no liberties exactly when libs[h] is 0, and p is its only
+liberty exactly when libs[h] equals the number of its stones touching p
+
+adding a stone can only merge chains, but taking stones off can split
+them, so chains that lose stones get rebuilt with a flood fill
+flood fills use scratch space kept on the board, so moves,
+legality checks and navigation don't allocate
+
+applying diffs (navigating the tree) only writes the stones and marks
+the chains stale, and the next question about chains rebuilds them all
+at once, so jumping around the tree costs no more than before
+*/
+
 type Board struct {
 	Size   int
 	Points [][]Color
+	Rules  Ruleset
+
+	stones []Color
+	// the four neighbors of each point, -1 off the board
+	adj   []int32
+	head  []int32
+	next  []int32
+	libs  []int32
+	count []int32
+	stale bool
+
+	// scratch space
+	mark   []uint32
+	epoch  uint32
+	stack  []int32
+	work   []int32
+	dirty  []int32
+	lifted []int32
 }
 
 func NewBoard(size int) *Board {
-	points := [][]Color{}
-	for i := 0; i < size; i++ {
-		row := make([]Color, size)
-		points = append(points, row)
-	}
-	return &Board{
+	n := size * size
+	b := &Board{
 		Size:   size,
-		Points: points,
+		Rules:  Japanese,
+		stones: make([]Color, n),
+		adj:    adjacency(size),
+		head:   make([]int32, n),
+		next:   make([]int32, n),
+		libs:   make([]int32, n),
+		count:  make([]int32, n),
+	}
+	for i := range b.head {
+		b.head[i] = -1
+	}
+	b.init()
+	return b
+}
+
+// init sets up the row views and scratch space
+func (b *Board) init() {
+	n := b.Size * b.Size
+	b.Points = make([][]Color, b.Size)
+	for j := range b.Points {
+		b.Points[j] = b.stones[j*b.Size : (j+1)*b.Size : (j+1)*b.Size]
+	}
+	b.mark = make([]uint32, n)
+	b.epoch = 0
+	b.stack = make([]int32, 0, n)
+	b.work = make([]int32, 0, n)
+	b.dirty = make([]int32, 0, n)
+	b.lifted = make([]int32, 0, n)
+}
+
+func adjacency(size int) []int32 {
+	adj := make([]int32, 4*size*size)
+	for y := 0; y < size; y++ {
+		for x := 0; x < size; x++ {
+			p := 4 * (y*size + x)
+			nbs := [4][2]int{{x - 1, y}, {x + 1, y}, {x, y - 1}, {x, y + 1}}
+			for k, nb := range nbs {
+				if nb[0] < 0 || nb[1] < 0 || nb[0] >= size || nb[1] >= size {
+					adj[p+k] = -1
+				} else {
+					adj[p+k] = int32(nb[1]*size + nb[0])
+				}
+			}
+		}
 	}
+	return adj
 }
 
 func (b *Board) String() string {
@@ -247,25 +362,265 @@ func (b *Board) String() string {
 }
 
 func (b *Board) Clear() {
-	for i := 0; i < b.Size; i++ {
-		for j := 0; j < b.Size; j++ {
-			b.Points[i][j] = NoColor
+	for i := range b.stones {
+		b.stones[i] = NoColor
+		b.head[i] = -1
+	}
+	b.stale = false
+}
+
+// sync rebuilds every chain if the stones were written directly
+func (b *Board) sync() {
+	if !b.stale {
+		return
+	}
+	b.stale = false
+	b.newEpoch()
+	for p, col := range b.stones {
+		if col == NoColor {
+			b.head[p] = -1
+		} else if b.mark[p] != b.epoch {
+			b.rebuild(int32(p))
 		}
 	}
 }
 
 func (b *Board) Copy() *Board {
-	c := NewBoard(b.Size)
-	for i := 0; i < b.Size; i++ {
-		for j := 0; j < b.Size; j++ {
-			c.Points[i][j] = b.Points[i][j]
+	c := &Board{
+		Size:   b.Size,
+		Rules:  b.Rules,
+		stones: append([]Color(nil), b.stones...),
+		// never written after construction, so it can be shared
+		adj:   b.adj,
+		head:  append([]int32(nil), b.head...),
+		next:  append([]int32(nil), b.next...),
+		libs:  append([]int32(nil), b.libs...),
+		count: append([]int32(nil), b.count...),
+		stale: b.stale,
+	}
+	c.init()
+	return c
+}
+
+// Key is a compact encoding of the position, for comparing positions
+func (b *Board) Key() string {
+	key := make([]byte, len(b.stones))
+	for i, c := range b.stones {
+		key[i] = byte(c)
+	}
+	return string(key)
+}
+
+func (b *Board) point(c *Coord) int32 {
+	return int32(c.Y*b.Size + c.X)
+}
+
+func (b *Board) coord(p int32) *Coord {
+	return &Coord{int(p) % b.Size, int(p) / b.Size}
+}
+
+// newEpoch invalidates all the marks from the last flood fill
+func (b *Board) newEpoch() {
+	b.epoch++
+	if b.epoch == 0 {
+		for i := range b.mark {
+			b.mark[i] = 0
 		}
+		b.epoch = 1
 	}
-	return c
+}
+
+// place puts a stone on p, merging it into the chains it touches
+func (b *Board) place(p int32, col Color) {
+	if b.stones[p] == col {
+		return
+	}
+	if b.stones[p] != NoColor {
+		b.lifted = append(b.lifted[:0], p)
+		b.lift(b.lifted)
+	}
+	b.stones[p] = col
+	b.head[p] = p
+	b.next[p] = p
+	b.count[p] = 1
+	b.libs[p] = 0
+	for k := int32(0); k < 4; k++ {
+		q := b.adj[4*p+k]
+		if q < 0 {
+			continue
+		}
+		if b.stones[q] == NoColor {
+			b.libs[p]++
+		} else {
+			// p was a liberty of q's chain
+			b.libs[b.head[q]]--
+		}
+	}
+	for k := int32(0); k < 4; k++ {
+		q := b.adj[4*p+k]
+		if q >= 0 && b.stones[q] == col {
+			b.merge(b.head[p], b.head[q])
+		}
+	}
+}
+
+// merge joins two chains, relabeling the smaller one
+func (b *Board) merge(h1, h2 int32) {
+	if h1 == h2 {
+		return
+	}
+	if b.count[h1] < b.count[h2] {
+		h1, h2 = h2, h1
+	}
+	p := h2
+	for {
+		b.head[p] = h1
+		p = b.next[p]
+		if p == h2 {
+			break
+		}
+	}
+	// splice the two circular lists together
+	b.next[h1], b.next[h2] = b.next[h2], b.next[h1]
+	b.count[h1] += b.count[h2]
+	b.libs[h1] += b.libs[h2]
+}
+
+// lift takes stones off the board and rebuilds the chains they were in
+func (b *Board) lift(points []int32) {
+	// every chain losing a stone is dirty
+	// (points gets filtered down to the stones actually lifted)
+	b.newEpoch()
+	b.dirty = b.dirty[:0]
+	n := 0
+	for _, p := range points {
+		if b.stones[p] == NoColor {
+			continue
+		}
+		points[n] = p
+		n++
+		h := b.head[p]
+		if b.mark[h] != b.epoch {
+			b.mark[h] = b.epoch
+			b.dirty = append(b.dirty, h)
+		}
+		b.stones[p] = NoColor
+	}
+	points = points[:n]
+
+	// clean chains next to the lifted stones gain liberties
+	for _, p := range points {
+		for k := int32(0); k < 4; k++ {
+			q := b.adj[4*p+k]
+			if q < 0 || b.stones[q] == NoColor {
+				continue
+			}
+			if h := b.head[q]; b.mark[h] != b.epoch {
+				b.libs[h]++
+			}
+		}
+	}
+
+	// what's left of the dirty chains
+	b.work = b.work[:0]
+	for _, h := range b.dirty {
+		p := h
+		for {
+			if b.stones[p] != NoColor {
+				b.work = append(b.work, p)
+			}
+			p = b.next[p]
+			if p == h {
+				break
+			}
+		}
+	}
+	for _, p := range points {
+		b.head[p] = -1
+	}
+
+	b.newEpoch()
+	for _, p := range b.work {
+		if b.mark[p] != b.epoch {
+			b.rebuild(p)
+		}
+	}
+}
+
+// rebuild flood fills the chain at start from scratch
+func (b *Board) rebuild(start int32) {
+	col := b.stones[start]
+	var count, libs int32
+	b.next[start] = start
+	b.mark[start] = b.epoch
+	stack := append(b.stack[:0], start)
+	for len(stack) > 0 {
+		p := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		b.head[p] = start
+		if p != start {
+			b.next[p] = b.next[start]
+			b.next[start] = p
+		}
+		count++
+
+		for k := int32(0); k < 4; k++ {
+			q := b.adj[4*p+k]
+			if q < 0 {
+				continue
+			}
+			if b.stones[q] == NoColor {
+				libs++
+			} else if b.stones[q] == col && b.mark[q] != b.epoch {
+				b.mark[q] = b.epoch
+				stack = append(stack, q)
+			}
+		}
+	}
+	b.stack = stack
+	b.count[start] = count
+	b.libs[start] = libs
+}
+
+// touching counts the stones of the chain at h next to p
+func (b *Board) touching(h, p int32) int32 {
+	var n int32
+	for k := int32(0); k < 4; k++ {
+		q := b.adj[4*p+k]
+		if q >= 0 && b.head[q] == h {
+			n++
+		}
+	}
+	return n
+}
+
+// chain lists the stones of the chain at h
+func (b *Board) chain(h int32) []*Coord {
+	coords := make([]*Coord, 0, b.count[h])
+	p := h
+	for {
+		coords = append(coords, b.coord(p))
+		p = b.next[p]
+		if p == h {
+			break
+		}
+	}
+	return coords
 }
 
 func (b *Board) Set(c *Coord, col Color) {
-	b.Points[c.Y][c.X] = col
+	p := b.point(c)
+	if b.stale {
+		b.stones[p] = col
+		return
+	}
+	if col == NoColor {
+		b.lifted = append(b.lifted[:0], p)
+		b.lift(b.lifted)
+		return
+	}
+	b.place(p, col)
 }
 
 func (b *Board) Get(c *Coord) Color {
@@ -273,37 +628,42 @@ func (b *Board) Get(c *Coord) Color {
 		log.Println(c)
 		return NoColor
 	}
-	return b.Points[c.Y][c.X]
+	return b.stones[b.point(c)]
 }
 
 func (b *Board) SetMany(cs []*Coord, col Color) {
+	if b.stale {
+		for _, c := range cs {
+			b.stones[b.point(c)] = col
+		}
+		return
+	}
+	if col == NoColor {
+		b.lifted = b.lifted[:0]
+		for _, c := range cs {
+			b.lifted = append(b.lifted, b.point(c))
+		}
+		b.lift(b.lifted)
+		return
+	}
 	for _, c := range cs {
-		b.Set(c, col)
+		b.place(b.point(c), col)
 	}
 }
 
 func (b *Board) Neighbors(c *Coord) CoordSet {
 	nbs := NewCoordSet()
-	for x := -1; x <= 1; x++ {
-		for y := -1; y <= 1; y++ {
-			if (x != 0 && y != 0) || (x == 0 && y == 0) {
-				continue
-			}
-			newX := c.X + x
-			newY := c.Y + y
-			if newX < 0 || newY < 0 {
-				continue
-			}
-			if newX >= b.Size || newY >= b.Size {
-				continue
-			}
-			nbs.Add(&Coord{newX, newY})
+	p := b.point(c)
+	for k := int32(0); k < 4; k++ {
+		if q := b.adj[4*p+k]; q >= 0 {
+			nbs.Add(b.coord(q))
 		}
 	}
 	return nbs
 }
 
 func (b *Board) FindGroup(start *Coord) *Group {
+	b.sync()
 	// get the color of the starting point
 	col := b.Get(start)
 
@@ -312,67 +672,74 @@ func (b *Board) FindGroup(start *Coord) *Group {
 		return NewGroup(nil, nil, NoColor)
 	}
 
-	// initiate the stack
-	stack := []*Coord{start}
-
-	// keep track of liberties as we go
-	// map so we don't double count
-	libs := NewCoordSet()
-
-	// initiate elements
 	elts := NewCoordSet()
-
-	// start DFS
-	for len(stack) > 0 {
-		// pop off the stack
-		point := stack[len(stack)-1]
-		stack = stack[:len(stack)-1]
-
-		// add to elements
-		elts.Add(point)
-
-		// compute neighbors
-		nbs := b.Neighbors(point)
-		for _, nb := range nbs {
-			// if it's the right color
-			// and we haven't visited it yet
-			// add to the stack
-			if b.Get(nb) == col && !elts.Has(nb) {
-				stack = append(stack, nb)
-			} else if b.Get(nb) == NoColor {
-				libs.Add(nb)
+	libs := NewCoordSet()
+	h := b.head[b.point(start)]
+	p := h
+	for {
+		elts.Add(b.coord(p))
+		for k := int32(0); k < 4; k++ {
+			q := b.adj[4*p+k]
+			if q >= 0 && b.stones[q] == NoColor {
+				libs.Add(b.coord(q))
 			}
 		}
+		p = b.next[p]
+		if p == h {
+			break
+		}
 	}
 	return NewGroup(elts, libs, col)
 }
 
 func (b *Board) Groups() []*Group {
-	// keep track of which points we've covered
-	check := make(map[[2]int]bool)
-
+	b.sync()
 	groups := []*Group{}
 
-	// go through the whole board
+	// go through the whole board, one group per chain
+	b.newEpoch()
 	for i := 0; i < b.Size; i++ {
 		for j := 0; j < b.Size; j++ {
-			coord := &Coord{i, j}
-			// if we haven't checked it yet and there's a stone here
-			if !check[[2]int{i, j}] && b.Get(coord) != NoColor {
-				// find the group it's part of
-				gp := b.FindGroup(coord)
-				for _, c := range gp.Coords {
-					// check off everything in the group
-					check[[2]int{c.X, c.Y}] = true
-				}
-				// add to the list of groups
-				groups = append(groups, gp)
+			p := int32(j*b.Size + i)
+			h := b.head[p]
+			if h == -1 || b.mark[h] == b.epoch {
+				continue
 			}
+			b.mark[h] = b.epoch
+			groups = append(groups, b.FindGroup(b.coord(p)))
 		}
 	}
 	return groups
 }
 
+// suicide reports whether col at the empty point p would have no
+// liberties after capturing
+func (b *Board) suicide(p int32, col Color) bool {
+	for k := int32(0); k < 4; k++ {
+		q := b.adj[4*p+k]
+		if q < 0 {
+			continue
+		}
+		switch b.stones[q] {
+		case NoColor:
+			return false
+		case col:
+			// connecting to a chain with another liberty
+			h := b.head[q]
+			if b.libs[h] > b.touching(h, p) {
+				return false
+			}
+		default:
+			// capturing a chain in atari
+			h := b.head[q]
+			if b.libs[h] == b.touching(h, p) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
 func (b *Board) Legal(start *Coord, col Color) bool {
 	// if there's already a stone there, it's illegal
 	if b.Get(start) != NoColor {
@@ -380,58 +747,53 @@ func (b *Board) Legal(start *Coord, col Color) bool {
 		// not legal
 	}
 
-	// this should be undone at the end
-	b.Set(start, col)
-	defer b.Set(start, NoColor)
-
-	// if it has >0 libs, it's legal
-	gp := b.FindGroup(start)
-	if len(gp.Libs) > 0 {
+	// some rulesets allow suicide, so any empty point is fine
+	if b.Rules.AllowsSuicide() {
 		return true
 	}
 
-	// check for any groups of opposite color with 0 libs
-	// only check neighboring area for optimization
-	nbs := b.Neighbors(start)
-	for _, nb := range nbs {
-		if b.Get(nb) == NoColor {
+	b.sync()
+	return !b.suicide(b.point(start), col)
+}
+
+// dead collects the chains of col next to p that have no liberties
+func (b *Board) dead(p int32, col Color) *StoneSet {
+	coords := []*Coord{}
+	var seen [4]int32
+	n := 0
+	for k := int32(0); k < 4; k++ {
+		q := b.adj[4*p+k]
+		if q < 0 || b.stones[q] != col {
 			continue
 		}
-		gp := b.FindGroup(nb)
-		if len(gp.Libs) == 0 && gp.Color == Opposite(col) {
-			// if we killed something, it's legal
-			return true
+		h := b.head[q]
+		if b.libs[h] != 0 {
+			continue
+		}
+		dup := false
+		for _, s := range seen[:n] {
+			dup = dup || s == h
 		}
+		if dup {
+			continue
+		}
+		seen[n] = h
+		n++
+		coords = append(coords, b.chain(h)...)
 	}
-
-	// if we have 0 libs and we didn't kill anything
-	// it's a suicide move (and not legal)
-	return false
+	return &StoneSet{coords, col}
 }
 
 func (b *Board) WouldKill(start *Coord, col Color) *StoneSet {
+	b.sync()
 	// we pretend a stone of color Opposite(col) was just played at start
 	a := b.Get(start)
-	b.Set(start, Opposite(col))
-	defer b.Set(start, a)
-	dead := NewCoordSet()
-	for _, nb := range b.Neighbors(start) {
-		// if we've already marked the stone dead
-		// or it's the wrong color
-		// just move on
-		if dead.Has(nb) || b.Get(nb) != col {
-			continue
-		}
-		// find the group
-		gp := b.FindGroup(nb)
-		// if it's dead, add each to the list
-		if len(gp.Libs) == 0 {
-			for _, coord := range gp.Coords {
-				dead.Add(coord)
-			}
-		}
+	p := b.point(start)
+	if a != Opposite(col) {
+		b.Set(start, Opposite(col))
+		defer b.Set(start, a)
 	}
-	return NewStoneSet(dead, col)
+	return b.dead(p, col)
 }
 
 func (b *Board) RemoveDead(start *Coord, col Color) *StoneSet {
@@ -441,22 +803,42 @@ func (b *Board) RemoveDead(start *Coord, col Color) *StoneSet {
 }
 
 func (b *Board) Move(start *Coord, col Color) *Diff {
+	b.sync()
 	// check to see if it's legal
 	if !b.Legal(start, col) {
 		return nil
 	}
 
 	// put the stone on the board
-	b.Set(start, col)
+	p := b.point(start)
+	b.place(p, col)
 
 	// remove dead groups of opposite color
-	remove := b.RemoveDead(start, Opposite(col))
+	remove := b.dead(p, Opposite(col))
+	b.SetMany(remove.Coords, NoColor)
+	removes := []*StoneSet{remove}
+
+	// if the move was suicide (only legal under some rulesets)
+	// the group that just got played takes itself off the board
+	if h := b.head[p]; b.libs[h] == 0 {
+		self := &StoneSet{b.chain(h), col}
+		b.SetMany(self.Coords, NoColor)
+		removes = append(removes, self)
+	}
 
 	// return diff
-	cs := NewCoordSet()
-	cs.Add(start)
-	add := NewStoneSet(cs, col)
-	return NewDiff([]*StoneSet{add}, []*StoneSet{remove})
+	add := &StoneSet{[]*Coord{start}, col}
+	return NewDiff([]*StoneSet{add}, removes)
+}
+
+// IsSuicide reports whether playing col at start would leave
+// the new stone's group with no liberties after captures
+func (b *Board) IsSuicide(start *Coord, col Color) bool {
+	b.sync()
+	if b.Get(start) != NoColor {
+		return false
+	}
+	return b.suicide(b.point(start), col)
 }
 
 func (b *Board) ApplyDiff(d *Diff) {
@@ -464,28 +846,49 @@ func (b *Board) ApplyDiff(d *Diff) {
 		return
 	}
 	for _, add := range d.Add {
-		b.SetMany(add.Coords, add.Color)
+		for _, c := range add.Coords {
+			b.stones[b.point(c)] = add.Color
+		}
+	}
+	for _, remove := range d.Remove {
+		for _, c := range remove.Coords {
+			b.stones[b.point(c)] = NoColor
+		}
+	}
+	b.stale = true
+}
+
+// UndoDiff is ApplyDiff(d.Invert()) without building the inverse
+func (b *Board) UndoDiff(d *Diff) {
+	if d == nil {
+		return
 	}
 	for _, remove := range d.Remove {
-		b.SetMany(remove.Coords, NoColor)
+		for _, c := range remove.Coords {
+			b.stones[b.point(c)] = remove.Color
+		}
 	}
+	for _, add := range d.Add {
+		for _, c := range add.Coords {
+			b.stones[b.point(c)] = NoColor
+		}
+	}
+	b.stale = true
 }
 
 func (b *Board) CurrentFrame() *Frame {
-	black := NewCoordSet()
-	white := NewCoordSet()
-	for j, row := range b.Points {
-		for i, c := range row {
-			if c == Black {
-				black.Add(&Coord{i, j})
-			} else if c == White {
-				white.Add(&Coord{i, j})
-			}
+	black := []*Coord{}
+	white := []*Coord{}
+	for p, c := range b.stones {
+		if c == Black {
+			black = append(black, b.coord(int32(p)))
+		} else if c == White {
+			white = append(white, b.coord(int32(p)))
 		}
 	}
-	addBlack := NewStoneSet(black, Black)
-	addWhite := NewStoneSet(white, White)
+	addBlack := &StoneSet{black, Black}
+	addWhite := &StoneSet{white, White}
 	diff := NewDiff([]*StoneSet{addBlack, addWhite}, nil)
 
-	return &Frame{FullFrame, diff, nil, nil, nil, nil}
+	return &Frame{Type: FullFrame, Diff: diff}
 }
@@ -13,6 +13,7 @@ package main_test
 import (
 	"fmt"
 	backend "github.com/jarednogo/board/backend"
+	"math/rand"
 	"testing"
 )
 
@@ -124,3 +125,308 @@ func TestBoard4(t *testing.T) {
 		t.Errorf("error in group coords, expected %v, got: %v", cs, gps[0].Coords)
 	}
 }
+
+func TestSuicideForbidden(t *testing.T) {
+	b := backend.NewBoard(19)
+	b.Move(&backend.Coord{1, 0}, backend.Black)
+	b.Move(&backend.Coord{0, 1}, backend.Black)
+	if b.Legal(&backend.Coord{0, 0}, backend.White) {
+		t.Errorf("suicide should be illegal under japanese rules")
+	}
+	if b.Move(&backend.Coord{0, 0}, backend.White) != nil {
+		t.Errorf("expected nil diff for illegal move")
+	}
+}
+
+func TestSuicideAllowed(t *testing.T) {
+	b := backend.NewBoard(19)
+	b.Rules = backend.NewZealand
+	b.Move(&backend.Coord{2, 0}, backend.Black)
+	b.Move(&backend.Coord{1, 1}, backend.Black)
+	b.Move(&backend.Coord{0, 2}, backend.Black)
+	b.Move(&backend.Coord{1, 0}, backend.White)
+	b.Move(&backend.Coord{0, 1}, backend.White)
+
+	// white fills its own last liberty
+	diff := b.Move(&backend.Coord{0, 0}, backend.White)
+	if diff == nil {
+		t.Fatalf("suicide should be legal under nz rules")
+	}
+	for _, c := range []*backend.Coord{{0, 0}, {1, 0}, {0, 1}} {
+		if b.Get(c) != backend.NoColor {
+			t.Errorf("expected %v to be removed", c)
+		}
+	}
+
+	removed := 0
+	for _, r := range diff.Remove {
+		if r.Color == backend.White {
+			removed += len(r.Coords)
+		}
+	}
+	if removed != 3 {
+		t.Errorf("expected 3 white stones in diff remove, got: %d", removed)
+	}
+
+	// undoing the diff should restore the position
+	b.ApplyDiff(diff.Invert())
+	if b.Get(&backend.Coord{1, 0}) != backend.White || b.Get(&backend.Coord{0, 0}) != backend.NoColor {
+		t.Errorf("error inverting suicide diff")
+	}
+}
+
+var rulesetTests = []struct {
+	input  string
+	output backend.Ruleset
+}{
+	{"Japanese", backend.Japanese},
+	{"chinese", backend.Chinese},
+	{"NZ", backend.NewZealand},
+	{"New Zealand", backend.NewZealand},
+	{"Tromp-Taylor", backend.TrompTaylor},
+	{"something else", backend.Japanese},
+}
+
+func TestParseRuleset(t *testing.T) {
+	for _, tt := range rulesetTests {
+		t.Run(tt.input, func(t *testing.T) {
+			if r := backend.ParseRuleset(tt.input); r != tt.output {
+				t.Errorf("expected %v, got: %v", tt.output, r)
+			}
+		})
+	}
+}
+
+func TestHandicapPoints(t *testing.T) {
+	for _, size := range []int{9, 13, 19} {
+		for n := 2; n <= 9; n++ {
+			points := backend.HandicapPoints(size, n)
+			if len(points) != n {
+				t.Errorf("expected %d points on %d, got: %d", n, size, len(points))
+			}
+		}
+	}
+	if backend.HandicapPoints(19, 10) != nil {
+		t.Errorf("10 stones should not have a fixed placement")
+	}
+}
+
+// naiveMove is a straightforward reference implementation of a move,
+// returning the new position or nil if the move is illegal
+func naiveMove(points [][]backend.Color, x, y int, col backend.Color, suicide bool) [][]backend.Color {
+	size := len(points)
+	if points[y][x] != backend.NoColor {
+		return nil
+	}
+	next := make([][]backend.Color, size)
+	for j := range points {
+		next[j] = append([]backend.Color(nil), points[j]...)
+	}
+	next[y][x] = col
+
+	group := func(x, y int) ([][2]int, bool) {
+		c := next[y][x]
+		seen := map[[2]int]bool{{x, y}: true}
+		stack := [][2]int{{x, y}}
+		stones := [][2]int{}
+		libs := false
+		for len(stack) > 0 {
+			p := stack[len(stack)-1]
+			stack = stack[:len(stack)-1]
+			stones = append(stones, p)
+			for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+				q := [2]int{p[0] + d[0], p[1] + d[1]}
+				if q[0] < 0 || q[1] < 0 || q[0] >= size || q[1] >= size || seen[q] {
+					continue
+				}
+				if next[q[1]][q[0]] == backend.NoColor {
+					libs = true
+				} else if next[q[1]][q[0]] == c {
+					seen[q] = true
+					stack = append(stack, q)
+				}
+			}
+		}
+		return stones, libs
+	}
+
+	for _, d := range [][2]int{{-1, 0}, {1, 0}, {0, -1}, {0, 1}} {
+		qx, qy := x+d[0], y+d[1]
+		if qx < 0 || qy < 0 || qx >= size || qy >= size || next[qy][qx] != backend.Opposite(col) {
+			continue
+		}
+		if stones, libs := group(qx, qy); !libs {
+			for _, p := range stones {
+				next[p[1]][p[0]] = backend.NoColor
+			}
+		}
+	}
+	if stones, libs := group(x, y); !libs {
+		if !suicide {
+			return nil
+		}
+		for _, p := range stones {
+			next[p[1]][p[0]] = backend.NoColor
+		}
+	}
+	return next
+}
+
+func samePoints(a, b [][]backend.Color) bool {
+	for j := range a {
+		for i := range a[j] {
+			if a[j][i] != b[j][i] {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func TestBoardRandom(t *testing.T) {
+	for _, rules := range []backend.Ruleset{backend.Japanese, backend.NewZealand} {
+		r := rand.New(rand.NewSource(1))
+		b := backend.NewBoard(9)
+		b.Rules = rules
+		col := backend.Black
+		for n := 0; n < 5000; n++ {
+			x, y := r.Intn(9), r.Intn(9)
+			want := naiveMove(b.Points, x, y, col, rules.AllowsSuicide())
+			if b.Legal(&backend.Coord{x, y}, col) != (want != nil) {
+				t.Fatalf("move %d: legality of %v at (%d, %d) disagrees", n, col, x, y)
+			}
+			if want == nil {
+				continue
+			}
+
+			// moves and their inverse diffs
+			diff := b.Move(&backend.Coord{x, y}, col)
+			if !samePoints(b.Points, want) {
+				t.Fatalf("move %d: board disagrees\n%v", n, b)
+			}
+			if n%7 == 0 {
+				before := b.Copy()
+				b.ApplyDiff(diff.Invert())
+				b.ApplyDiff(diff)
+				if b.Key() != before.Key() {
+					t.Fatalf("move %d: undo and redo changed the board", n)
+				}
+			}
+			col = backend.Opposite(col)
+
+			// start over now and then
+			if r.Intn(200) == 0 {
+				b.Clear()
+			}
+		}
+	}
+}
+
+func TestGotoIndexRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(2))
+	s := backend.NewState(9, true)
+
+	// a tree with plenty of captures and branches
+	for n := 0; n < 1500; n++ {
+		if r.Intn(20) == 0 {
+			s.GotoIndex(r.Intn(s.NextIndex))
+		}
+		c := &backend.Coord{r.Intn(9), r.Intn(9)}
+		col := backend.Color(1 + n%2)
+		if s.Board.Legal(c, col) {
+			s.AddNode(c, col, nil, -1, true)
+		}
+	}
+
+	// replay each node from the root with the reference implementation
+	for k := 0; k < 300; k++ {
+		index := r.Intn(s.NextIndex)
+		node, ok := s.Nodes[index]
+		if !ok {
+			continue
+		}
+		path := []*backend.TreeNode{}
+		for cur := node; cur.Up != nil; cur = cur.Up {
+			path = append(path, cur)
+		}
+		want := backend.NewBoard(9).Points
+		for i := len(path) - 1; i >= 0; i-- {
+			want = naiveMove(want, path[i].XY.X, path[i].XY.Y, path[i].Color, false)
+		}
+
+		s.GotoIndex(index)
+		if !samePoints(s.Board.Points, want) {
+			t.Fatalf("board at node %d disagrees", index)
+		}
+	}
+}
+
+func BenchmarkMove(b *testing.B) {
+	r := rand.New(rand.NewSource(3))
+	coords := make([]*backend.Coord, 1000)
+	for i := range coords {
+		coords[i] = &backend.Coord{r.Intn(19), r.Intn(19)}
+	}
+	board := backend.NewBoard(19)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%len(coords) == 0 {
+			board.Clear()
+		}
+		board.Move(coords[i%len(coords)], backend.Color(1+i%2))
+	}
+}
+
+func BenchmarkLegal(b *testing.B) {
+	r := rand.New(rand.NewSource(4))
+	board := backend.NewBoard(19)
+	for i := 0; i < 250; i++ {
+		board.Move(&backend.Coord{r.Intn(19), r.Intn(19)}, backend.Color(1+i%2))
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		c := &backend.Coord{i % 19, (i / 19) % 19}
+		board.Legal(c, backend.Color(1+i%2))
+	}
+}
+
+// jumping between variations that split at the root
+func BenchmarkGotoIndexFar(b *testing.B) {
+	s, err := backend.FromSGF(bigGame(20))
+	if err != nil {
+		b.Fatal(err)
+	}
+	// the last node of every variation
+	leaves := []int{}
+	for _, n := range s.Nodes {
+		if len(n.Down) == 0 {
+			leaves = append(leaves, n.Index)
+		}
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		s.GotoIndex(leaves[i%len(leaves)])
+	}
+}
+
+// jumping a few moves back and forth near the end of a long game
+func BenchmarkGotoIndexNear(b *testing.B) {
+	s, err := backend.FromSGF(bigGame(1))
+	if err != nil {
+		b.Fatal(err)
+	}
+	s.FastForward()
+	last := s.Current.Index
+	for i := 0; i < 10; i++ {
+		s.Left()
+	}
+	earlier := s.Current.Index
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if i%2 == 0 {
+			s.GotoIndex(last)
+		} else {
+			s.GotoIndex(earlier)
+		}
+	}
+}
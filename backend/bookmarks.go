@@ -0,0 +1,54 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+// a named node (SGF N[]), so anyone can jump to it
+type Bookmark struct {
+	Index int    `json:"index"`
+	Name  string `json:"name"`
+}
+
+// NodeName is the node's N[] property, or "" if it doesn't have one
+func (n *TreeNode) NodeName() string {
+	if names, ok := n.Fields["N"]; ok && len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+// SetNodeName names the current node, an empty name clears it
+func (s *State) SetNodeName(name string) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		s.ClearNodeName()
+		return
+	}
+	s.Current.Fields["N"] = []string{name}
+}
+
+func (s *State) ClearNodeName() {
+	delete(s.Current.Fields, "N")
+}
+
+// Bookmarks lists the named nodes in tree order
+func (s *State) Bookmarks() []*Bookmark {
+	bookmarks := []*Bookmark{}
+	Fmap(func(n *TreeNode) {
+		if name := n.NodeName(); name != "" {
+			bookmarks = append(bookmarks, &Bookmark{n.Index, name})
+		}
+	}, s.Root)
+	return bookmarks
+}
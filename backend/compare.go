@@ -0,0 +1,147 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// a move on one side of a comparison
+type CompareMove struct {
+	Index int    `json:"index"`
+	Coord *Coord `json:"coord"`
+	Color Color  `json:"color"`
+}
+
+// Comparison is how two nodes differ, from where their lines split
+type Comparison struct {
+	A        int `json:"a"`
+	B        int `json:"b"`
+	Ancestor int `json:"ancestor"`
+	// the nodes after the ancestor on the way to A and to B
+	MovesA []*CompareMove `json:"moves_a"`
+	MovesB []*CompareMove `json:"moves_b"`
+	// stones on the board at A and not at B, and the other way round
+	// (a point with different colors is in both)
+	OnlyA []*StoneSet `json:"only_a"`
+	OnlyB []*StoneSet `json:"only_b"`
+	// stones captured on the way from the ancestor
+	CapturedA []*StoneSet `json:"captured_a"`
+	CapturedB []*StoneSet `json:"captured_b"`
+	// total captures at each end
+	CapturesA Captures `json:"captures_a"`
+	CapturesB Captures `json:"captures_b"`
+}
+
+// CommonAncestor is the deepest node that both a and b are under
+// (either may be the ancestor itself)
+func CommonAncestor(a, b *TreeNode) *TreeNode {
+	da, db := a.Depth(), b.Depth()
+	for ; da > db; da-- {
+		a = a.Up
+	}
+	for ; db > da; db-- {
+		b = b.Up
+	}
+	for a != b {
+		a = a.Up
+		b = b.Up
+	}
+	return a
+}
+
+// line is the nodes below ancestor on the way to n, in order
+func line(ancestor, n *TreeNode) []*TreeNode {
+	nodes := []*TreeNode{}
+	for cur := n; cur != ancestor; cur = cur.Up {
+		nodes = append(nodes, cur)
+	}
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+	return nodes
+}
+
+func compareMoves(nodes []*TreeNode) []*CompareMove {
+	moves := []*CompareMove{}
+	for _, n := range nodes {
+		moves = append(moves, &CompareMove{n.Index, n.XY, n.Color})
+	}
+	return moves
+}
+
+// captured gathers the stones moves took off the board
+func captured(nodes []*TreeNode) []*StoneSet {
+	sets := map[Color]*StoneSet{
+		Black: {[]*Coord{}, Black},
+		White: {[]*Coord{}, White},
+	}
+	for _, n := range nodes {
+		if n.XY == nil || n.Diff == nil {
+			continue
+		}
+		for _, r := range n.Diff.Remove {
+			if set, ok := sets[r.Color]; ok {
+				set.Coords = append(set.Coords, r.Coords...)
+			}
+		}
+	}
+	return []*StoneSet{sets[Black], sets[White]}
+}
+
+// Compare works out how the nodes at indexes a and b differ
+func (s *State) Compare(a, b int) (*Comparison, error) {
+	na, ok := s.Nodes[a]
+	if !ok {
+		return nil, fmt.Errorf("no node %d", a)
+	}
+	nb, ok := s.Nodes[b]
+	if !ok {
+		return nil, fmt.Errorf("no node %d", b)
+	}
+	ancestor := CommonAncestor(na, nb)
+	lineA := line(ancestor, na)
+	lineB := line(ancestor, nb)
+
+	boardA := s.BoardAt(na)
+	boardB := s.BoardAt(nb)
+	onlyA := []*StoneSet{{[]*Coord{}, Black}, {[]*Coord{}, White}}
+	onlyB := []*StoneSet{{[]*Coord{}, Black}, {[]*Coord{}, White}}
+	for y := 0; y < s.Size; y++ {
+		for x := 0; x < s.Size; x++ {
+			c := &Coord{x, y}
+			ca, cb := boardA.Get(c), boardB.Get(c)
+			if ca == cb {
+				continue
+			}
+			if ca != NoColor {
+				onlyA[ca-1].Coords = append(onlyA[ca-1].Coords, c)
+			}
+			if cb != NoColor {
+				onlyB[cb-1].Coords = append(onlyB[cb-1].Coords, c)
+			}
+		}
+	}
+
+	return &Comparison{
+		a,
+		b,
+		ancestor.Index,
+		compareMoves(lineA),
+		compareMoves(lineB),
+		onlyA,
+		onlyB,
+		captured(lineA),
+		captured(lineB),
+		na.Captures,
+		nb.Captures,
+	}, nil
+}
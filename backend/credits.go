@@ -0,0 +1,179 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the custom SGF property credits are kept in
+// each value is kind:item:nickname:unix time, where the item says which
+// comment (its index in C), pen stroke (its index in PX) or mark (its
+// point) the credit is for, and is empty for the node itself
+// e.g. XA[node::alice:1735689600] or XA[TR:cc:bob:1735689660]
+const CreditKey = "XA"
+
+// which credit kind (an SGF property, or "node") each event adds
+var creditKinds = map[string]string{
+	"comment":  "C",
+	"triangle": "TR",
+	"square":   "SQ",
+	"letter":   "LB",
+	"number":   "LB",
+	"draw":     "PX",
+}
+
+// Credit says who added something to a node, and when
+type Credit struct {
+	Kind string `json:"kind"`
+	// which comment or mark, see CreditKey
+	Item   string `json:"item"`
+	Author string `json:"author"`
+	Time   int64  `json:"time"`
+}
+
+func (c *Credit) String() string {
+	return c.Kind + ":" + c.Item + ":" + c.Author + ":" + strconv.FormatInt(c.Time, 10)
+}
+
+// ParseCredit reads a credit from an XA value
+// (nicknames may have colons in them, kinds, items and times don't)
+func ParseCredit(value string) *Credit {
+	parts := strings.SplitN(value, ":", 3)
+	if len(parts) < 3 {
+		return nil
+	}
+	last := strings.LastIndex(parts[2], ":")
+	if last == -1 {
+		return nil
+	}
+	t, err := strconv.ParseInt(parts[2][last+1:], 10, 64)
+	if err != nil {
+		return nil
+	}
+	return &Credit{parts[0], parts[1], parts[2][:last], t}
+}
+
+// parseCredits reads the credits out of a new node's fields
+func parseCredits(fields map[string][]string) []*Credit {
+	credits := []*Credit{}
+	for _, v := range fields[CreditKey] {
+		if c := ParseCredit(v); c != nil {
+			credits = append(credits, c)
+		}
+	}
+	return credits
+}
+
+// Credits lists who added what to the node
+func (n *TreeNode) Credits() []*Credit {
+	return n.credits
+}
+
+// Author is whoever made the node, or "" if nobody is credited
+func (n *TreeNode) Author() string {
+	for _, c := range n.credits {
+		if c.Kind == "node" {
+			return c.Author
+		}
+	}
+	return ""
+}
+
+// Credit records c on the node, replacing the earlier credit
+// for the same comment or mark (or the node itself)
+func (n *TreeNode) Credit(c *Credit) {
+	n.dropCredits(func(old *Credit) bool {
+		return old.Kind == c.Kind && old.Item == c.Item
+	})
+	n.credits = append(n.credits, c)
+	n.saveCredits()
+}
+
+// Uncredit drops the credits for the marks of the given kinds at a
+// point, once they're taken off the node
+func (n *TreeNode) Uncredit(item string, kinds ...string) {
+	n.dropCredits(func(old *Credit) bool {
+		for _, kind := range kinds {
+			if old.Kind == kind && old.Item == item {
+				return true
+			}
+		}
+		return false
+	})
+	n.saveCredits()
+}
+
+// UncreditAll drops every credit of a kind
+func (n *TreeNode) UncreditAll(kind string) {
+	n.dropCredits(func(old *Credit) bool {
+		return old.Kind == kind
+	})
+	n.saveCredits()
+}
+
+func (n *TreeNode) dropCredits(drop func(*Credit) bool) {
+	kept := []*Credit{}
+	for _, c := range n.credits {
+		if !drop(c) {
+			kept = append(kept, c)
+		}
+	}
+	n.credits = kept
+}
+
+// saveCredits writes the credits back into XA
+func (n *TreeNode) saveCredits() {
+	if len(n.credits) == 0 {
+		delete(n.Fields, CreditKey)
+		return
+	}
+	values := []string{}
+	for _, c := range n.credits {
+		values = append(values, c.String())
+	}
+	n.Fields[CreditKey] = values
+}
+
+// track puts a node the state just made in s.Nodes, crediting it to
+// whoever is making it (see addEvent)
+func (s *State) track(n *TreeNode) {
+	s.Nodes[n.Index] = n
+	if s.maker != nil {
+		c := *s.maker
+		n.Credit(&c)
+	}
+}
+
+// credit records the comment or mark an event added to the current
+// node, given how many values its property had before
+func (s *State) credit(evt *EventJSON, author string, at time.Time, had int) {
+	kind, ok := creditKinds[evt.Event]
+	if !ok {
+		return
+	}
+	values := s.Current.Fields[kind]
+	if len(values) <= had {
+		// nothing was added
+		return
+	}
+	// comments and pen strokes go by index, marks by their point
+	item := strconv.Itoa(len(values) - 1)
+	switch kind {
+	case "TR", "SQ":
+		item = values[len(values)-1]
+	case "LB":
+		item = strings.SplitN(values[len(values)-1], ":", 2)[0]
+	}
+	s.Current.Credit(&Credit{kind, item, author, at.Unix()})
+}
@@ -0,0 +1,223 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+/*
+by default everyone in a room looks at (and moves) the shared current
+node, but a connection can have a private cursor instead, or follow
+someone else's
+
+edits made through a private cursor still go into the shared tree,
+so after one everyone else gets a fresh frame
+scoring and the tactics overlay belong to the room, so they can't be
+changed from a private cursor
+*/
+
+// following the room cursor rather than a user
+const FollowRoom = "room"
+
+// Cursor is a private place in the shared tree, with its own board
+type Cursor struct {
+	Current *TreeNode
+	Board   *Board
+	// who this cursor follows, FollowRoom, or "" for nobody
+	Follow string
+}
+
+// NewCursor starts a private cursor at the current node
+func (s *State) NewCursor() *Cursor {
+	return &Cursor{s.Current, s.Board.Copy(), ""}
+}
+
+// swap trades the state's current node and board for the cursor's,
+// so events can run as usual, and trades them back when called again
+func (s *State) swap(c *Cursor) {
+	s.Current, c.Current = c.Current, s.Current
+	s.Board, c.Board = c.Board, s.Board
+}
+
+// BoardAt works out the board at any node from the root
+func (s *State) BoardAt(n *TreeNode) *Board {
+	path := []*TreeNode{}
+	for cur := n; cur != nil; cur = cur.Up {
+		path = append(path, cur)
+	}
+	board := s.Board.Copy()
+	board.Clear()
+	for i := len(path) - 1; i >= 0; i-- {
+		board.ApplyDiff(path[i].Diff)
+	}
+	return board
+}
+
+// Resync puts a cursor back on the tree after an edit
+// if its node is gone it moves up to the closest node that's left
+// (or the root, if the whole tree was replaced), and the board is
+// worked out again since diffs on the way there may have changed
+func (s *State) Resync(c *Cursor) {
+	n := c.Current
+	for n != nil && s.Nodes[n.Index] != n {
+		n = n.Up
+	}
+	if n == nil {
+		n = s.Root
+	}
+	c.Current = n
+	c.Board = s.BoardAt(n)
+}
+
+// AddEventAt is AddEvent at a private cursor
+func (s *State) AddEventAt(c *Cursor, evt *EventJSON) (*Frame, error) {
+	s.swap(c)
+	defer s.swap(c)
+	return s.AddEvent(evt)
+}
+
+// IsNavigation says whether an event only moves the cursor
+// (or only looks at the tree)
+func IsNavigation(event string) bool {
+	switch event {
+	case "left", "right", "up", "down", "rewind", "fastforward",
+		"goto_grid", "goto_coord", "goto_transposition", "compare":
+		return true
+	}
+	return false
+}
+
+// RoomOnly says whether an event changes something every cursor
+// shares (scoring and the tactics overlay), so it can only be made
+// from the room's cursor
+func RoomOnly(event string) bool {
+	switch event {
+	case "start_scoring", "toggle_dead", "confirm_score", "cancel_scoring",
+		"toggle_tactics":
+		return true
+	}
+	return false
+}
+
+// NodeEvent says whether an event is passed on as it is but only
+// makes sense at the node it was made on
+func NodeEvent(event string) bool {
+	switch event {
+	case "triangle", "square", "letter", "number", "remove_mark",
+		"comment", "draw", "erase_pen":
+		return true
+	}
+	return false
+}
+
+// Cursor is the private cursor of a connection, or nil
+func (r *Room) Cursor(id string) *Cursor {
+	return r.cursors[id]
+}
+
+// ViewOf says whose private cursor a connection is looking through,
+// or "" for the room's
+func (r *Room) ViewOf(id string) string {
+	seen := make(map[string]bool)
+	for !seen[id] {
+		seen[id] = true
+		c, ok := r.cursors[id]
+		if !ok || c.Follow == FollowRoom {
+			return ""
+		}
+		if c.Follow == "" {
+			return id
+		}
+		id = c.Follow
+	}
+	// people following each other in a loop
+	return ""
+}
+
+// Unfollow leaves a private cursor where the one it followed is
+func (r *Room) Unfollow(id string) {
+	c, ok := r.cursors[id]
+	if !ok || c.Follow == "" {
+		return
+	}
+	if view := r.ViewOf(id); view == "" {
+		c.Current = r.State.Current
+		c.Board = r.State.Board.Copy()
+	} else {
+		v := r.cursors[view]
+		c.Current = v.Current
+		c.Board = v.Board.Copy()
+	}
+	c.Follow = ""
+}
+
+// Follow makes id look through someone else's cursor (or the room's)
+// following someone who isn't there (any more) is following the room
+func (r *Room) Follow(id, target string) error {
+	if target == id {
+		return fmt.Errorf("can't follow yourself")
+	}
+	c, ok := r.cursors[id]
+	if !ok {
+		c = &Cursor{}
+		r.cursors[id] = c
+	}
+	c.Follow = target
+	return nil
+}
+
+// ViewFrame is a full frame as seen through a private cursor,
+// or the room's if view is ""
+func (r *Room) ViewFrame(view string) *Frame {
+	c, ok := r.cursors[view]
+	if !ok {
+		frame := r.State.GenerateFullFrame(true)
+		r.Decorate(frame)
+		return frame
+	}
+	r.State.swap(c)
+	defer r.State.swap(c)
+	frame := r.State.GenerateFullFrame(true)
+	r.Decorate(frame)
+	return frame
+}
+
+// SendView sends an event to everyone looking through the same
+// cursor (the event should already be decorated)
+func (r *Room) SendView(view string, evt *EventJSON) {
+	frame, isFrame := evt.Value.(*Frame)
+	for id := range r.conns {
+		if r.ViewOf(id) != view {
+			continue
+		}
+		e := evt
+		if isFrame {
+			masked := *evt
+			masked.Value = r.FrameFor(id, frame)
+			e = &masked
+		}
+		r.SendTo(id, e)
+	}
+}
+
+// RefreshViews puts every private cursor back on the tree after an
+// edit, and sends everyone looking through one a fresh frame
+// (apart from the view that made the edit)
+func (r *Room) RefreshViews(except string) {
+	for id, c := range r.cursors {
+		if c.Follow != "" || id == except {
+			continue
+		}
+		r.State.Resync(c)
+		r.SendView(id, FrameJSON(r.ViewFrame(id)))
+	}
+}
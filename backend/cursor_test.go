@@ -0,0 +1,108 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+func TestPrivateCursor(t *testing.T) {
+	room := backend.NewRoom()
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg];B[ee])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room.State = s
+	event := func(name string, value interface{}, id string) {
+		evt := &backend.EventJSON{name, value, int(backend.Black), id}
+		if name == "private_cursor" || name == "follow" {
+			room.HandleCursor(evt)
+		} else {
+			room.HandleEvent(evt)
+		}
+	}
+
+	// a private cursor moves on its own
+	event("private_cursor", true, "alice")
+	event("fastforward", nil, "alice")
+	c := room.Cursor("alice")
+	if c.Current.Depth() != 3 || s.Current != s.Root {
+		t.Fatalf("expected only alice to move, got: %d %d", c.Current.Depth(), s.Current.Depth())
+	}
+	if c.Board.Get(&backend.Coord{4, 4}) != backend.Black || s.Board.Get(&backend.Coord{2, 2}) != backend.NoColor {
+		t.Errorf("expected the boards to be separate")
+	}
+
+	// bob follows alice, then moving breaks away from where alice is
+	event("follow", "alice", "bob")
+	if room.ViewOf("bob") != "alice" {
+		t.Errorf("expected bob to look through alice's cursor")
+	}
+	event("left", nil, "bob")
+	b := room.Cursor("bob")
+	if room.ViewOf("bob") != "bob" || b.Current.Depth() != 2 || c.Current.Depth() != 3 {
+		t.Errorf("expected bob to move separately from alice's node")
+	}
+
+	// edits from a private cursor go into the shared tree
+	event("add_stone", []interface{}{0.0, 0.0}, "bob")
+	if len(b.Current.Up.Down) != 2 || s.Current != s.Root {
+		t.Errorf("expected a new branch without moving the room")
+	}
+
+	// and cutting under someone else's cursor moves it up
+	event("goto_grid", float64(s.Root.Down[0].Index), "carol")
+	event("cut", nil, "carol")
+	if s.Current != s.Root {
+		t.Fatalf("expected the room to be back at the root")
+	}
+	for _, id := range []string{"alice", "bob"} {
+		cur := room.Cursor(id)
+		if cur.Current != s.Root || cur.Board.Get(&backend.Coord{2, 2}) != backend.NoColor {
+			t.Errorf("expected %s back at the root", id)
+		}
+	}
+
+	event("private_cursor", false, "alice")
+	if room.Cursor("alice") != nil || room.ViewOf("alice") != "" {
+		t.Errorf("expected alice back on the room cursor")
+	}
+}
+
+func TestPrivateCursorRoomOnly(t *testing.T) {
+	room := backend.NewRoom()
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room.State = s
+	room.HandleCursor(&backend.EventJSON{"private_cursor", true, 0, "alice"})
+	room.HandleEvent(&backend.EventJSON{"fastforward", nil, 0, "alice"})
+
+	// scoring and tactics belong to the room
+	for _, name := range []string{"toggle_tactics", "start_scoring"} {
+		room.HandleEvent(&backend.EventJSON{name, nil, 0, "alice"})
+	}
+	if s.Tactics || s.Scoring != nil {
+		t.Errorf("expected a private cursor not to change the room")
+	}
+	if room.Cursor("alice").Current.Depth() != 2 || s.Current != s.Root {
+		t.Errorf("expected both cursors to stay put")
+	}
+
+	// following the room is the same as being on it
+	room.HandleCursor(&backend.EventJSON{"follow", backend.FollowRoom, 0, "alice"})
+	room.HandleEvent(&backend.EventJSON{"toggle_tactics", nil, 0, "alice"})
+	if !s.Tactics || room.ViewOf("alice") != "" {
+		t.Errorf("expected the room's tactics to change for someone following it")
+	}
+}
@@ -12,6 +12,7 @@ package main
 
 import (
 	"encoding/base64"
+	"fmt"
 	"log"
 	"strconv"
 	"strings"
@@ -54,47 +55,64 @@ func HandlePing(evt *EventJSON) *EventJSON {
 
 func (room *Room) HandleUploadSGF(evt *EventJSON) *EventJSON {
 	var bcast *EventJSON
+	files := []*NamedFile{}
+	rejected := []*SkippedSGF{}
 
 	// it might be a string
 	if str, ok := evt.Value.(string); ok {
-
 		decoded, err := base64.StdEncoding.DecodeString(str)
 		if err != nil {
 			bcast = ErrorJSON(err.Error())
-		}
-		if IsZipFile(decoded) {
-			filesBytes, err := Decompress(decoded)
+		} else if IsZipFile(decoded) {
+			files, err = DecompressFiles(decoded)
 			if err != nil {
 				bcast = ErrorJSON(err.Error())
-			} else {
-				files := []string{}
-				for _, file := range filesBytes {
-					files = append(files, string(file))
-				}
-				merged := Merge(files)
-				bcast = room.UploadSGF(merged)
 			}
 		} else {
-			bcast = room.UploadSGF(string(decoded))
+			files = append(files, &NamedFile{"upload", decoded})
 		}
 
 	} else if arr, ok := evt.Value.([]interface{}); ok {
-		// it might be an array of strings
-		sgfs := []string{}
-		for _, ifc := range arr {
-			str := ifc.(string)
+		// it might be an array of strings, or of {"name", "data"}
+		for i, ifc := range arr {
+			name := fmt.Sprintf("file %d", i+1)
+			str, _ := ifc.(string)
+			if m, ok := ifc.(map[string]interface{}); ok {
+				if n, ok := m["name"].(string); ok {
+					name = n
+				}
+				str, _ = m["data"].(string)
+			}
 			d, err := base64.StdEncoding.DecodeString(str)
 			if err != nil {
-				bcast = ErrorJSON(err.Error())
+				rejected = append(rejected, &SkippedSGF{-1, err.Error(), name})
+				continue
 			}
-			sgfs = append(sgfs, string(d))
+			files = append(files, &NamedFile{name, d})
 		}
-		sgf := Merge(sgfs)
-		bcast = room.UploadSGF(sgf)
 	} else {
 		bcast = ErrorJSON("unreachable")
 	}
 
+	if bcast == nil {
+		bcast = room.UploadFiles(files, rejected)
+	}
+
+	room.SendUploadInfo(evt.UserID)
+
+	bcast.UserID = evt.UserID
+	return bcast
+}
+
+// HandleChooseGame opens a single game out of the last uploaded collection
+func (room *Room) HandleChooseGame(evt *EventJSON) *EventJSON {
+	var bcast *EventJSON
+	index, ok := evt.Value.(float64)
+	if !ok || int(index) < 0 || int(index) >= len(room.collection) {
+		bcast = ErrorJSON("no such game in collection")
+	} else {
+		bcast = room.UploadSGF(room.collection[int(index)])
+	}
 	bcast.UserID = evt.UserID
 	return bcast
 }
@@ -161,8 +179,14 @@ func (room *Room) HandleRequestSGF(evt *EventJSON) *EventJSON {
 		bcast = ErrorJSON(err.Error())
 	} else if data == "Permission denied" {
 		bcast = ErrorJSON("Error fetching SGF. Is it a private OGS game?")
+	} else if games, err := GamesFromBytes([]byte(data)); err != nil {
+		bcast = ErrorJSON(err.Error())
 	} else {
-		bcast = room.UploadSGF(string(data))
+		bcast = room.UploadGames(games)
+		for _, s := range room.skipped {
+			s.File = evt.Value.(string)
+		}
+		room.SendUploadInfo(evt.UserID)
 	}
 
 	return bcast
@@ -172,10 +196,13 @@ func (room *Room) HandleTrash(evt *EventJSON) *EventJSON {
 
 	// reset room
 	oldBuffer := room.State.InputBuffer
+	oldRules := room.State.Board.Rules
 	room.State = NewState(room.State.Size, true)
 
-	// reuse old inputbuffer
+	// reuse old inputbuffer and rules
 	room.State.InputBuffer = oldBuffer
+	room.State.SetRules(oldRules)
+	room.handicap = 0
 
 	frame := room.State.GenerateFullFrame(true)
 	bcast := FrameJSON(frame)
@@ -208,13 +235,55 @@ func (room *Room) HandleUpdateSettings(evt *EventJSON) *EventJSON {
 	if password != "" {
 		hashed = Hash(password)
 	}
-	settings := &Settings{buffer, size, hashed}
+	// older clients don't send rules
+	rules := ""
+	if r, ok := sMap["rules"].(string); ok {
+		rules = r
+	}
+	// same with handicap
+	handicap := -1
+	if h, ok := sMap["handicap"].(float64); ok {
+		handicap = int(h)
+	}
+	free := false
+	if mode, ok := sMap["handicap_mode"].(string); ok {
+		free = mode == "free"
+	}
+	settings := &Settings{buffer, size, hashed, rules, handicap, free}
 
 	room.State.InputBuffer = settings.Buffer
-	if settings.Size != room.State.Size {
-		// essentially trashing
-		room.State = NewState(settings.Size, true)
-		room.State.InputBuffer = buffer
+	// a stale or default handicap from the client isn't a change
+	newHandicap := settings.Handicap != -1 && settings.Handicap != room.handicap
+	if settings.Size != room.State.Size || newHandicap {
+		n := room.handicap
+		if settings.Handicap != -1 {
+			n = settings.Handicap
+		}
+		if err := ValidateHandicap(settings.Size, n, settings.FreeHandicap); err != nil {
+			room.SendTo(evt.UserID, ErrorJSON(err.Error()))
+		} else {
+			// essentially trashing
+			oldRules := room.State.Board.Rules
+			room.State = NewState(settings.Size, true)
+			room.State.InputBuffer = buffer
+			room.State.SetRules(oldRules)
+			if n > 0 {
+				room.State.SetHandicap(n, settings.FreeHandicap)
+			}
+			room.handicap = n
+		}
+	}
+
+	if settings.Rules != "" {
+		room.State.SetRules(ParseRuleset(settings.Rules))
+	}
+
+	// variants belong to the room, not the game
+	if v, ok := sMap["variant"].(string); ok {
+		room.variant = ParseVariant(v)
+	}
+	if auto, ok := sMap["auto_color"].(bool); ok {
+		room.autoColor = auto
 	}
 
 	// can be changed
@@ -229,12 +298,76 @@ func (room *Room) HandleUpdateSettings(evt *EventJSON) *EventJSON {
 }
 
 func (room *Room) HandleEvent(evt *EventJSON) *EventJSON {
+	c, private := room.cursors[evt.UserID]
+	if private && RoomOnly(evt.Event) {
+		// following the room is as good as being on the shared cursor
+		if room.ViewOf(evt.UserID) != "" {
+			msg := fmt.Sprintf("%s can't be used from a private cursor", evt.Event)
+			room.SendTo(evt.UserID, ErrorJSON(msg))
+			return NopJSON()
+		}
+		private = false
+	}
+	if !private {
+		bcast := room.apply(evt)
+		if !IsNavigation(evt.Event) {
+			room.RefreshViews("")
+		}
+		return bcast
+	}
+
+	// moving a private cursor stops following
+	room.Unfollow(evt.UserID)
+	room.State.swap(c)
+	bcast := room.apply(evt)
+	if frame, ok := bcast.Value.(*Frame); ok {
+		room.Decorate(frame)
+	}
+	room.State.swap(c)
+	room.SendView(evt.UserID, bcast)
+
+	if !IsNavigation(evt.Event) {
+		// the shared tree changed under everyone else
+		c := &Cursor{room.State.Current, room.State.Board, ""}
+		room.State.Resync(c)
+		room.State.Current, room.State.Board = c.Current, c.Board
+		shared := FrameJSON(room.State.GenerateFullFrame(true))
+		shared.UserID = evt.UserID
+		room.Broadcast(shared, true)
+		room.RefreshViews(evt.UserID)
+	}
+	return NopJSON()
+}
+
+// apply runs an event at the state's current node
+func (room *Room) apply(evt *EventJSON) *EventJSON {
 	var bcast *EventJSON
-	frame, err := room.State.AddEvent(evt)
-	if err != nil {
-		bcast = ErrorJSON(err.Error())
+
+	// atari-go stops at the first capture
+	if room.variant == AtariGo && playsMove(evt.Event) {
+		if winner := room.State.FirstCapture(); winner != NoColor {
+			name := "black"
+			if winner == White {
+				name = "white"
+			}
+			room.SendTo(evt.UserID, ErrorJSON("game over: "+name+" captured first"))
+			return NopJSON()
+		}
+	}
+
+	if room.autoColor {
+		room.State.AssignColor(evt)
 	}
-	if frame != nil {
+
+	frame, err := room.State.AddEventBy(evt, room.nicks[evt.UserID], time.Now())
+	if err != nil {
+		// errors only go back to whoever caused them
+		room.SendTo(evt.UserID, ErrorJSON(err.Error()))
+		bcast = NopJSON()
+	} else if frame != nil {
+		if frame.Replay != nil {
+			room.SendTo(evt.UserID, &EventJSON{"replay_report", frame.Replay, 0, ""})
+		}
 		bcast = FrameJSON(frame)
 	} else {
 		bcast = evt
@@ -243,6 +376,86 @@ func (room *Room) HandleEvent(evt *EventJSON) *EventJSON {
 	return bcast
 }
 
+// HandleSpectate lets someone see the real board in a masking variant
+func (room *Room) HandleSpectate(evt *EventJSON) *EventJSON {
+	spectate, _ := evt.Value.(bool)
+	if spectate {
+		room.spectators[evt.UserID] = true
+	} else {
+		delete(room.spectators, evt.UserID)
+	}
+	frame := room.ViewFrame(room.ViewOf(evt.UserID))
+	room.SendTo(evt.UserID, FrameJSON(room.FrameFor(evt.UserID, frame)))
+	return evt
+}
+
+// HandleExplorerWindow sets how far a connection's explorer reaches
+// around its node (0 means the whole tree)
+func (room *Room) HandleExplorerWindow(evt *EventJSON) *EventJSON {
+	size, ok := evt.Value.(float64)
+	if !ok || size < 0 {
+		room.SendTo(evt.UserID, ErrorJSON("invalid explorer window"))
+		return evt
+	}
+	if size == 0 {
+		delete(room.windows, evt.UserID)
+	} else {
+		room.windows[evt.UserID] = int(size)
+	}
+	frame := room.ViewFrame(room.ViewOf(evt.UserID))
+	room.SendTo(evt.UserID, FrameJSON(room.FrameFor(evt.UserID, frame)))
+	return evt
+}
+
+// HandleCursor switches a connection between the shared cursor,
+// a private one and following someone
+func (room *Room) HandleCursor(evt *EventJSON) *EventJSON {
+	id := evt.UserID
+	switch evt.Event {
+	case "private_cursor":
+		private, _ := evt.Value.(bool)
+		if !private {
+			delete(room.cursors, id)
+		} else if _, ok := room.cursors[id]; ok {
+			room.Unfollow(id)
+		} else {
+			room.cursors[id] = room.State.NewCursor()
+		}
+	case "follow":
+		target, _ := evt.Value.(string)
+		if target == "" {
+			room.Unfollow(id)
+		} else if err := room.Follow(id, target); err != nil {
+			room.SendTo(id, ErrorJSON(err.Error()))
+			return evt
+		}
+	}
+	frame := room.ViewFrame(room.ViewOf(id))
+	room.SendTo(id, FrameJSON(room.FrameFor(id, frame)))
+	return evt
+}
+
+// HandleSearch sends search results back to whoever searched
+func (room *Room) HandleSearch(evt *EventJSON) *EventJSON {
+	// colors and moves are hidden from players in these variants
+	if room.variant.Masks() && !room.spectators[evt.UserID] {
+		room.SendTo(evt.UserID, ErrorJSON("only spectators can search in this variant"))
+		return evt
+	}
+	q, err := ParseSearch(evt.Value)
+	if err != nil {
+		room.SendTo(evt.UserID, ErrorJSON(err.Error()))
+		return evt
+	}
+	results, err := room.State.Search(q)
+	if err != nil {
+		room.SendTo(evt.UserID, ErrorJSON(err.Error()))
+		return evt
+	}
+	room.SendTo(evt.UserID, &EventJSON{"search_results", results, 0, ""})
+	return evt
+}
+
 // middleware
 
 func (room *Room) BroadcastAfter(setTime bool) Middleware {
@@ -265,6 +478,16 @@ func (room *Room) BroadcastFullFrameAfter(handler EventHandler) EventHandler {
 	}
 }
 
+// RefreshViewsAfter is for handlers that replace or reset the state,
+// which private cursors need to hear about
+func (room *Room) RefreshViewsAfter(handler EventHandler) EventHandler {
+	return func(evt *EventJSON) *EventJSON {
+		evt = handler(evt)
+		room.RefreshViews("")
+		return evt
+	}
+}
+
 func (room *Room) BroadcastConnectedUsersAfter(handler EventHandler) EventHandler {
 	return func(evt *EventJSON) *EventJSON {
 		evt = handler(evt)
@@ -0,0 +1,141 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// moveFields are the fields of a new move or pass node
+func moveFields(coord *Coord, col Color) map[string][]string {
+	key := "B"
+	if col == White {
+		key = "W"
+	}
+	value := ""
+	if coord != nil {
+		value = coord.ToLetters()
+	}
+	return map[string][]string{key: {value}}
+}
+
+// returnTo goes back to the node at index after an edit,
+// unless the edit took it out of the tree
+func (s *State) returnTo(index int) {
+	if _, ok := s.Nodes[index]; ok {
+		s.GotoIndex(index)
+	}
+}
+
+// DeleteNode takes a single node out of the tree, keeping its children
+// in its place under its parent, replayed from there
+func (s *State) DeleteNode(index int) (*ReplayReport, error) {
+	s.changed()
+	n, ok := s.Nodes[index]
+	if !ok {
+		return nil, fmt.Errorf("no node %d", index)
+	}
+	if n.Up == nil {
+		return nil, fmt.Errorf("can't delete the root")
+	}
+	save := s.Current.Index
+	parent := n.Up
+	s.GotoIndex(parent.Index)
+	delete(s.Nodes, n.Index)
+
+	report := NewReplayReport()
+	board := s.Board.Copy()
+	history := s.History()
+	i := parent.ChildIndex(n)
+	kept := []*TreeNode{}
+	preferred := -1
+	for j, child := range n.Down {
+		if s.replay(child, parent, board, history, report) {
+			if j == n.PreferredChild {
+				preferred = i + len(kept)
+			}
+			kept = append(kept, child)
+		}
+	}
+
+	down := append([]*TreeNode{}, parent.Down[:i]...)
+	down = append(down, kept...)
+	down = append(down, parent.Down[i+1:]...)
+	parent.Down = down
+
+	// the preferred child follows the one that was preferred below n
+	if parent.PreferredChild == i {
+		if preferred == -1 {
+			preferred = i
+		}
+		parent.PreferredChild = preferred
+	} else if parent.PreferredChild > i {
+		parent.PreferredChild += len(kept) - 1
+	}
+	if parent.PreferredChild >= len(parent.Down) {
+		parent.PreferredChild = 0
+	}
+
+	s.returnTo(save)
+	return report, nil
+}
+
+// InsertNode puts a move (or a pass, if coord is nil) right after the
+// current node, with the current node's children moving below it
+func (s *State) InsertNode(coord *Coord, col Color) (*ReplayReport, error) {
+	s.changed()
+	if coord != nil {
+		if reason := replayReason(s.Board, s.History(), coord, col); reason != "" {
+			return nil, fmt.Errorf("can't insert a move at %s (%s)", coord.ToLetters(), reason)
+		}
+	}
+
+	cur := s.Current
+	n := NewTreeNode(coord, col, s.GetNextIndex(), cur, moveFields(coord, col))
+	s.track(n)
+	n.Down = cur.Down
+	n.PreferredChild = cur.PreferredChild
+
+	report := NewReplayReport()
+	s.replay(n, cur, s.Board.Copy(), s.History(), report)
+	cur.Down = []*TreeNode{n}
+	cur.PreferredChild = 0
+
+	s.GotoIndex(n.Index)
+	return report, nil
+}
+
+// ReplaceMove changes the coordinate of the move at the current node,
+// replaying everything below it
+func (s *State) ReplaceMove(coord *Coord) (*ReplayReport, error) {
+	n := s.Current
+	if n.Up == nil || (n.Color != Black && n.Color != White) {
+		return nil, fmt.Errorf("only moves can be replaced")
+	}
+
+	s.Left()
+	if reason := replayReason(s.Board, s.History(), coord, n.Color); reason != "" {
+		s.GotoIndex(n.Index)
+		return nil, fmt.Errorf("can't move to %s (%s)", coord.ToLetters(), reason)
+	}
+
+	// keep any other fields (comments, marks)
+	for key, value := range moveFields(coord, n.Color) {
+		n.Fields[key] = value
+	}
+	n.XY = coord
+
+	report := NewReplayReport()
+	s.replay(n, n.Up, s.Board.Copy(), s.History(), report)
+
+	s.GotoIndex(n.Index)
+	return report, nil
+}
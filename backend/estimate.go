@@ -0,0 +1,353 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+/*
+a score estimator that doesn't need a neural network
+
+1. benson's algorithm finds groups that are alive no matter what,
+   along with the regions they enclose
+2. weak groups (in atari, or with few liberties that are all in the
+   opponent's sphere of influence) are taken off the board as dead
+3. bouzy's 5/21 algorithm (5 dilations, 21 erosions) spreads the
+   influence of the remaining stones to decide who owns the empty points
+
+it's a rough guess, good enough to answer "who's ahead here?"
+the margin is counted by area
+*/
+
+type Estimate struct {
+	// ownership[y][x] is 1 for black, -1 for white, 0 for neither
+	Ownership [][]int `json:"ownership"`
+	Margin    float64 `json:"margin"`
+	Result    string  `json:"result"`
+}
+
+const (
+	bouzyDilations = 5
+	bouzyErosions  = 21
+	bouzyStone     = 128
+)
+
+// EstimateScore guesses ownership of every point and the margin
+// (from black's point of view, komi included)
+func EstimateScore(b *Board, komi float64) *Estimate {
+	board := b.Copy()
+	size := board.Size
+
+	// unconditionally alive groups and their regions are settled
+	settled := make([][]Color, size)
+	for i := range settled {
+		settled[i] = make([]Color, size)
+	}
+	for _, col := range []Color{Black, White} {
+		for _, c := range UnconditionallyAlive(board, col) {
+			settled[c.Y][c.X] = col
+		}
+	}
+
+	// take weak groups off the board
+	influence := Bouzy(board, bouzyDilations, bouzyErosions)
+	for _, gp := range board.Groups() {
+		if gp.Color == NoColor {
+			continue
+		}
+		first := gp.Coords.List()[0]
+		if settled[first.Y][first.X] == gp.Color {
+			continue
+		}
+		if groupIsDead(gp, influence) {
+			board.SetMany(gp.Coords.List(), NoColor)
+		}
+	}
+
+	// and spread influence from what's left
+	influence = Bouzy(board, bouzyDilations, bouzyErosions)
+
+	ownership := make([][]int, size)
+	margin := -komi
+	for j := 0; j < size; j++ {
+		ownership[j] = make([]int, size)
+		for i := 0; i < size; i++ {
+			owner := 0
+			if settled[j][i] == Black {
+				owner = 1
+			} else if settled[j][i] == White {
+				owner = -1
+			} else if influence[j][i] > 0 {
+				owner = 1
+			} else if influence[j][i] < 0 {
+				owner = -1
+			}
+			ownership[j][i] = owner
+			margin += float64(owner)
+		}
+	}
+	return &Estimate{ownership, margin, ResultString(margin)}
+}
+
+// groupIsDead is the weak group heuristic: a group in atari is dead,
+// and so is a group with only a few liberties, all of which are in
+// the opponent's sphere of influence
+func groupIsDead(gp *Group, influence [][]int) bool {
+	if len(gp.Libs) <= 1 {
+		return true
+	}
+	if len(gp.Libs) > 3 {
+		return false
+	}
+	sign := 1
+	if gp.Color == White {
+		sign = -1
+	}
+	for _, lib := range gp.Libs {
+		if influence[lib.Y][lib.X]*sign >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// Bouzy runs the dilation/erosion influence algorithm
+// positive values are black's influence, negative are white's
+func Bouzy(b *Board, dilations, erosions int) [][]int {
+	size := b.Size
+	values := make([][]int, size)
+	for j := 0; j < size; j++ {
+		values[j] = make([]int, size)
+		for i := 0; i < size; i++ {
+			switch b.Points[j][i] {
+			case Black:
+				values[j][i] = bouzyStone
+			case White:
+				values[j][i] = -bouzyStone
+			}
+		}
+	}
+
+	neighbors := func(i, j int, f func(v int)) {
+		if i > 0 {
+			f(values[j][i-1])
+		}
+		if i < size-1 {
+			f(values[j][i+1])
+		}
+		if j > 0 {
+			f(values[j-1][i])
+		}
+		if j < size-1 {
+			f(values[j+1][i])
+		}
+	}
+
+	next := make([][]int, size)
+	for j := range next {
+		next[j] = make([]int, size)
+	}
+
+	for n := 0; n < dilations; n++ {
+		for j := 0; j < size; j++ {
+			for i := 0; i < size; i++ {
+				v := values[j][i]
+				pos, neg := 0, 0
+				neighbors(i, j, func(w int) {
+					if w > 0 {
+						pos++
+					} else if w < 0 {
+						neg++
+					}
+				})
+				if v >= 0 && neg == 0 {
+					v += pos
+				}
+				if v <= 0 && pos == 0 {
+					v -= neg
+				}
+				next[j][i] = v
+			}
+		}
+		values, next = next, values
+	}
+
+	for n := 0; n < erosions; n++ {
+		for j := 0; j < size; j++ {
+			for i := 0; i < size; i++ {
+				v := values[j][i]
+				if v > 0 {
+					neighbors(i, j, func(w int) {
+						if w <= 0 {
+							v--
+						}
+					})
+					if v < 0 {
+						v = 0
+					}
+				} else if v < 0 {
+					neighbors(i, j, func(w int) {
+						if w >= 0 {
+							v++
+						}
+					})
+					if v > 0 {
+						v = 0
+					}
+				}
+				next[j][i] = v
+			}
+		}
+		values, next = next, values
+	}
+	return values
+}
+
+// UnconditionallyAlive runs benson's algorithm for col and returns
+// the stones that can't be captured, plus the regions they enclose
+func UnconditionallyAlive(b *Board, col Color) []*Coord {
+	size := b.Size
+
+	// chains of col, by id
+	chainOf := make([]int, size*size)
+	for i := range chainOf {
+		chainOf[i] = -1
+	}
+	chains := []*Group{}
+	for _, gp := range b.Groups() {
+		if gp.Color != col {
+			continue
+		}
+		for _, c := range gp.Coords {
+			chainOf[c.Y*size+c.X] = len(chains)
+		}
+		chains = append(chains, gp)
+	}
+
+	// regions: maximal connected sets of points that aren't col
+	regionOf := make([]int, size*size)
+	for i := range regionOf {
+		regionOf[i] = -1
+	}
+	regions := [][]*Coord{}
+	for j := 0; j < size; j++ {
+		for i := 0; i < size; i++ {
+			start := &Coord{i, j}
+			if b.Get(start) == col || regionOf[j*size+i] != -1 {
+				continue
+			}
+			id := len(regions)
+			region := []*Coord{}
+			regionOf[j*size+i] = id
+			stack := []*Coord{start}
+			for len(stack) > 0 {
+				point := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				region = append(region, point)
+				for _, nb := range b.Neighbors(point) {
+					k := nb.Y*size + nb.X
+					if b.Get(nb) != col && regionOf[k] == -1 {
+						regionOf[k] = id
+						stack = append(stack, nb)
+					}
+				}
+			}
+			regions = append(regions, region)
+		}
+	}
+
+	// which chains border each region,
+	// and which chains each region is vital to
+	// (a region is vital to a chain if all its empty points are liberties of the chain)
+	bordering := make([]map[int]bool, len(regions))
+	vital := make([]map[int]bool, len(regions))
+	for id, region := range regions {
+		bordering[id] = make(map[int]bool)
+		for _, point := range region {
+			for _, nb := range b.Neighbors(point) {
+				if chain := chainOf[nb.Y*size+nb.X]; chain != -1 {
+					bordering[id][chain] = true
+				}
+			}
+		}
+		vital[id] = make(map[int]bool)
+		for chain := range bordering[id] {
+			isVital := true
+			for _, point := range region {
+				if b.Get(point) == NoColor && !chains[chain].Libs.Has(point) {
+					isVital = false
+					break
+				}
+			}
+			if isVital {
+				vital[id][chain] = true
+			}
+		}
+	}
+
+	aliveChains := make(map[int]bool)
+	for i := range chains {
+		aliveChains[i] = true
+	}
+	aliveRegions := make(map[int]bool)
+	for i := range regions {
+		aliveRegions[i] = true
+	}
+
+	for {
+		changed := false
+
+		// remove chains with fewer than two vital regions
+		for chain := range aliveChains {
+			count := 0
+			for region := range aliveRegions {
+				if vital[region][chain] {
+					count++
+				}
+			}
+			if count < 2 {
+				delete(aliveChains, chain)
+				changed = true
+			}
+		}
+
+		// remove regions that border a removed chain
+		for region := range aliveRegions {
+			for chain := range bordering[region] {
+				if !aliveChains[chain] {
+					delete(aliveRegions, region)
+					changed = true
+					break
+				}
+			}
+		}
+
+		if !changed {
+			break
+		}
+	}
+
+	alive := []*Coord{}
+	for chain := range aliveChains {
+		alive = append(alive, chains[chain].Coords.List()...)
+	}
+	// the regions that are vital to alive chains belong to col too
+	for region := range aliveRegions {
+		for chain := range vital[region] {
+			if aliveChains[chain] {
+				alive = append(alive, regions[region]...)
+				break
+			}
+		}
+	}
+	return alive
+}
+
+func (s *State) EstimateScore() *Estimate {
+	return EstimateScore(s.Board, s.Komi())
+}
@@ -0,0 +1,84 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+func setStones(b *backend.Board, col backend.Color, points ...string) {
+	for _, p := range points {
+		b.Set(backend.LettersToCoord(p), col)
+	}
+}
+
+func TestBensonAlive(t *testing.T) {
+	// black group in the corner with two eyes at aa and ca
+	b := backend.NewBoard(9)
+	setStones(b, backend.Black, "ba", "da", "ab", "bb", "cb", "db")
+	alive := backend.UnconditionallyAlive(b, backend.Black)
+	cs := backend.NewCoordSet()
+	for _, c := range alive {
+		cs.Add(c)
+	}
+	for _, p := range []string{"ba", "da", "bb", "aa", "ca"} {
+		if !cs.Has(backend.LettersToCoord(p)) {
+			t.Errorf("expected %s to be unconditionally alive", p)
+		}
+	}
+
+	// one eye isn't enough
+	b = backend.NewBoard(9)
+	setStones(b, backend.Black, "ba", "ab", "bb")
+	if len(backend.UnconditionallyAlive(b, backend.Black)) != 0 {
+		t.Errorf("expected one eyed group not to be alive")
+	}
+}
+
+func TestBouzy(t *testing.T) {
+	b := backend.NewBoard(9)
+	setStones(b, backend.Black, "cc", "cg")
+	setStones(b, backend.White, "gc", "gg")
+	values := backend.Bouzy(b, 5, 21)
+	if values[4][1] <= 0 {
+		t.Errorf("expected black influence on the left, got: %d", values[4][1])
+	}
+	if values[4][7] >= 0 {
+		t.Errorf("expected white influence on the right, got: %d", values[4][7])
+	}
+}
+
+func TestEstimateScore(t *testing.T) {
+	// black wall on column e, white wall on f
+	// a lone white stone inside black's area is dead
+	b := backend.NewBoard(9)
+	for _, y := range "abcdefghi" {
+		setStones(b, backend.Black, "e"+string(y))
+		setStones(b, backend.White, "f"+string(y))
+	}
+	setStones(b, backend.White, "bb")
+	b.Set(backend.LettersToCoord("ba"), backend.Black)
+	b.Set(backend.LettersToCoord("ab"), backend.Black)
+	b.Set(backend.LettersToCoord("cb"), backend.Black)
+
+	estimate := backend.EstimateScore(b, 6.5)
+	if estimate.Ownership[1][1] != 1 {
+		t.Errorf("expected dead white stone to be black's, got: %d", estimate.Ownership[1][1])
+	}
+	// 45 - 36 - 6.5
+	if estimate.Margin != 2.5 {
+		t.Errorf("expected margin 2.5, got: %v", estimate.Margin)
+	}
+	if estimate.Result != "B+2.5" {
+		t.Errorf("expected B+2.5, got: %s", estimate.Result)
+	}
+}
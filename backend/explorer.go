@@ -0,0 +1,88 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Explorer lays out the tree around the current node
+func (s *State) Explorer() *Explorer {
+	return s.ExplorerAt(s.Current, 0)
+}
+
+// ExplorerAt is the part of the explorer within size columns and rows
+// of n (size 0 means the whole tree)
+// the layout is kept until the tree changes (see changed)
+func (s *State) ExplorerAt(n *TreeNode, size int) *Explorer {
+	if s.grid == nil || s.grid.open != openPath(n) {
+		s.grid = s.Root.Layout(n)
+	}
+	return s.grid.Explorer(n.Index, size)
+}
+
+// changed throws away the layout (and tactics read at nodes that
+// may be gone) after the shape of the tree changes
+func (s *State) changed() {
+	s.grid = nil
+	s.tactics = nil
+}
+
+// Collapse hides (or shows again) the subtree below the node at index
+// collapsing a node above the current node moves up to it
+func (s *State) Collapse(index int, collapsed bool) error {
+	n, ok := s.Nodes[index]
+	if !ok {
+		return fmt.Errorf("no node %d", index)
+	}
+	n.Collapsed = collapsed
+	s.changed()
+	if !collapsed {
+		return nil
+	}
+	for cur := s.Current.Up; cur != nil; cur = cur.Up {
+		if cur == n {
+			return s.GotoIndex(index)
+		}
+	}
+	return nil
+}
+
+// ExpandAll shows every collapsed subtree
+func (s *State) ExpandAll() {
+	for _, n := range s.Nodes {
+		n.Collapsed = false
+	}
+	s.changed()
+}
+
+// CollapsedNodes lists the indexes of collapsed nodes, for saving
+func (s *State) CollapsedNodes() []int {
+	result := []int{}
+	for i, n := range s.Nodes {
+		if n.Collapsed {
+			result = append(result, i)
+		}
+	}
+	sort.Ints(result)
+	return result
+}
+
+// SetCollapsedNodes collapses the nodes at the given indexes
+func (s *State) SetCollapsedNodes(indexes []int) {
+	for _, i := range indexes {
+		if n, ok := s.Nodes[i]; ok {
+			n.Collapsed = true
+		}
+	}
+	s.changed()
+}
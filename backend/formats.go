@@ -0,0 +1,529 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+/*
+converters for the other game record formats floating around:
+GIB - Tygem (and Fox, which borrows the format)
+NGF - WBaduk / Cyberoro
+UGF, UGI - Pandanet / Hikaru
+
+none of these have real specs, so the parsing here follows what the
+files look like in practice and is forgiving about everything else
+each converter produces an SGFNode tree, the same thing the sgf parser
+produces, so everything downstream (merging, FromSGF) stays the same
+*/
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+type Format int
+
+const (
+	SGFFormat Format = iota
+	GIBFormat
+	NGFFormat
+	UGFFormat
+)
+
+func (f Format) String() string {
+	switch f {
+	case GIBFormat:
+		return "GIB"
+	case NGFFormat:
+		return "NGF"
+	case UGFFormat:
+		return "UGF"
+	}
+	return "SGF"
+}
+
+// DetectFormat guesses the format of a game record
+// anything unrecognized is assumed to be sgf
+func DetectFormat(data []byte) Format {
+	trimmed := bytes.TrimSpace(data)
+	if len(trimmed) > 0 && trimmed[0] == '(' {
+		return SGFFormat
+	}
+
+	if bytes.Contains(data, []byte("\\HS")) ||
+		bytes.Contains(data, []byte("\\[GAMEBLACKNAME=")) {
+		return GIBFormat
+	}
+
+	upper := bytes.ToUpper(data)
+	if bytes.Contains(upper, []byte("[HEADER]")) &&
+		bytes.Contains(upper, []byte("[DATA]")) {
+		return UGFFormat
+	}
+
+	lines := splitLines(string(data))
+	if len(lines) > 11 {
+		if _, err := strconv.Atoi(strings.TrimSpace(lines[1])); err == nil {
+			for _, line := range lines[12:] {
+				if strings.HasPrefix(strings.TrimSpace(line), "PM") {
+					return NGFFormat
+				}
+			}
+		}
+	}
+	return SGFFormat
+}
+
+// ConvertToSGF turns a game record in any supported format into sgf
+// sgf input is passed through untouched
+func ConvertToSGF(data []byte) (string, error) {
+	var root *SGFNode
+	var err error
+	format := DetectFormat(data)
+	switch format {
+	case SGFFormat:
+		return string(data), nil
+	case GIBFormat:
+		root, err = ParseGIB(string(data))
+	case NGFFormat:
+		root, err = ParseNGF(string(data))
+	case UGFFormat:
+		root, err = ParseUGF(string(data))
+	}
+	if err != nil {
+		return "", fmt.Errorf("error reading %s file: %s", format, err)
+	}
+	return root.ToSGF(true), nil
+}
+
+// GamesFromBytes converts an uploaded (or fetched) file into sgf games,
+// one per game tree
+func GamesFromBytes(data []byte) ([]string, error) {
+	sgf, err := ConvertToSGF(data)
+	if err != nil {
+		return nil, err
+	}
+	return SplitCollection(sgf)
+}
+
+func splitLines(s string) []string {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	s = strings.ReplaceAll(s, "\r", "\n")
+	return strings.Split(s, "\n")
+}
+
+// recordBuilder collects the root fields and the main line of moves
+type recordBuilder struct {
+	root    *SGFNode
+	current *SGFNode
+	size    int
+	last    Color
+}
+
+func newRecordBuilder() *recordBuilder {
+	fields := make(map[string][]string)
+	fields["GM"] = []string{"1"}
+	fields["FF"] = []string{"4"}
+	fields["CA"] = []string{"UTF-8"}
+	root := NewSGFNode(fields, 0)
+	return &recordBuilder{root, root, 19, NoColor}
+}
+
+func (r *recordBuilder) set(key, value string) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return
+	}
+	r.root.Fields[key] = []string{value}
+}
+
+func (r *recordBuilder) setSize(size int) {
+	if size < 2 || size > 19 {
+		return
+	}
+	r.size = size
+	r.root.Fields["SZ"] = []string{strconv.Itoa(size)}
+}
+
+func (r *recordBuilder) onBoard(x, y int) bool {
+	return x >= 0 && y >= 0 && x < r.size && y < r.size
+}
+
+// setup adds stones to the root (handicap stones, mostly)
+func (r *recordBuilder) setup(x, y int, col Color) {
+	if !r.onBoard(x, y) {
+		return
+	}
+	key := "AB"
+	if col == White {
+		key = "AW"
+	}
+	r.root.Fields[key] = append(r.root.Fields[key], (&Coord{x, y}).ToLetters())
+}
+
+func (r *recordBuilder) handicap(n int) {
+	points := HandicapPoints(r.size, n)
+	for _, p := range points {
+		r.setup(p.X, p.Y, Black)
+	}
+	if len(points) > 0 {
+		r.set("HA", strconv.Itoa(n))
+	}
+}
+
+// move plays at x, y, anything off the board is a pass
+func (r *recordBuilder) move(x, y int, col Color) {
+	key := "B"
+	if col == White {
+		key = "W"
+	}
+	value := ""
+	if r.onBoard(x, y) {
+		value = (&Coord{x, y}).ToLetters()
+	}
+	fields := make(map[string][]string)
+	fields[key] = []string{value}
+	n := NewSGFNode(fields, 0)
+	r.current.Down = append(r.current.Down, n)
+	r.current = n
+	r.last = col
+}
+
+// splitRank separates "name (rank)" or "name rank"
+func splitRank(s string) (string, string) {
+	s = strings.TrimSpace(s)
+	if i := strings.LastIndex(s, "("); i != -1 && strings.HasSuffix(s, ")") {
+		return strings.TrimSpace(s[:i]), strings.TrimSpace(s[i+1 : len(s)-1])
+	}
+	if i := strings.LastIndexAny(s, " \t"); i != -1 {
+		rank := strings.Trim(s[i+1:], "*")
+		if rankRegexp.MatchString(rank) {
+			return strings.TrimSpace(s[:i]), rank
+		}
+	}
+	return s, ""
+}
+
+var rankRegexp = regexp.MustCompile(`^(?i)\d+\s*[kdp]$`)
+
+// komiString formats komi given in tenths (65 -> 6.5)
+func komiString(tenths int) string {
+	return strconv.FormatFloat(float64(tenths)/10, 'f', -1, 64)
+}
+
+var (
+	gibResultRegexp = regexp.MustCompile(`GRLT:(\d+),`)
+	gibZipsuRegexp  = regexp.MustCompile(`ZIPSU:(\d+),`)
+	gibGongjeRegexp = regexp.MustCompile(`GONGJE:(\d+),`)
+	gibDateRegexp   = regexp.MustCompile(`,C(\d\d\d\d):(\d\d):(\d\d)`)
+	gibTagKomi      = regexp.MustCompile(`,G(\d+),`)
+	gibTagResult    = regexp.MustCompile(`,W(\d),`)
+	gibTagZipsu     = regexp.MustCompile(`,Z(\d+),`)
+)
+
+// gibResult turns a tygem result code and margin (in tenths) into RE
+func gibResult(code, zipsu int) string {
+	switch code {
+	case 0:
+		return "B+" + komiString(zipsu)
+	case 1:
+		return "W+" + komiString(zipsu)
+	case 3:
+		return "B+R"
+	case 4:
+		return "W+R"
+	case 7:
+		return "B+T"
+	case 8:
+		return "W+T"
+	}
+	return ""
+}
+
+func ParseGIB(data string) (*SGFNode, error) {
+	r := newRecordBuilder()
+	r.setSize(19)
+	moves := 0
+
+	for _, raw := range splitLines(data) {
+		line := strings.TrimSpace(raw)
+		switch {
+		case strings.HasPrefix(line, "\\[GAMEBLACKNAME=") && strings.HasSuffix(line, "\\]"):
+			name, rank := splitRank(line[16 : len(line)-2])
+			r.set("PB", name)
+			r.set("BR", rank)
+
+		case strings.HasPrefix(line, "\\[GAMEWHITENAME=") && strings.HasSuffix(line, "\\]"):
+			name, rank := splitRank(line[16 : len(line)-2])
+			r.set("PW", name)
+			r.set("WR", rank)
+
+		case strings.HasPrefix(line, "\\[GAMEINFOMAIN="):
+			if m := gibResultRegexp.FindStringSubmatch(line); m != nil {
+				code, _ := strconv.Atoi(m[1])
+				zipsu := 0
+				if z := gibZipsuRegexp.FindStringSubmatch(line); z != nil {
+					zipsu, _ = strconv.Atoi(z[1])
+				}
+				r.set("RE", gibResult(code, zipsu))
+			}
+			if m := gibGongjeRegexp.FindStringSubmatch(line); m != nil {
+				komi, _ := strconv.Atoi(m[1])
+				r.set("KM", komiString(komi))
+			}
+
+		case strings.HasPrefix(line, "\\[GAMETAG="):
+			if m := gibDateRegexp.FindStringSubmatch(line); m != nil {
+				r.set("DT", fmt.Sprintf("%s-%s-%s", m[1], m[2], m[3]))
+			}
+			if _, ok := r.root.Fields["RE"]; !ok {
+				if m := gibTagResult.FindStringSubmatch(line); m != nil {
+					code, _ := strconv.Atoi(m[1])
+					zipsu := 0
+					if z := gibTagZipsu.FindStringSubmatch(line); z != nil {
+						zipsu, _ = strconv.Atoi(z[1])
+					}
+					r.set("RE", gibResult(code, zipsu))
+				}
+			}
+			if _, ok := r.root.Fields["KM"]; !ok {
+				if m := gibTagKomi.FindStringSubmatch(line); m != nil {
+					komi, _ := strconv.Atoi(m[1])
+					r.set("KM", komiString(komi))
+				}
+			}
+
+		case strings.HasPrefix(line, "INI "):
+			// INI 0 1 <handicap> ...
+			tokens := strings.Fields(line)
+			if len(tokens) > 3 {
+				n, err := strconv.Atoi(tokens[3])
+				if err == nil {
+					r.handicap(n)
+				}
+			}
+
+		case strings.HasPrefix(line, "STO "):
+			// STO 0 <move number> <color> <x> <y>
+			tokens := strings.Fields(line)
+			if len(tokens) < 6 {
+				return nil, fmt.Errorf("bad move: %s", line)
+			}
+			col := Black
+			if tokens[3] == "2" {
+				col = White
+			}
+			x, err1 := strconv.Atoi(tokens[4])
+			y, err2 := strconv.Atoi(tokens[5])
+			if err1 != nil || err2 != nil {
+				return nil, fmt.Errorf("bad move: %s", line)
+			}
+			r.move(x, y, col)
+			moves++
+
+		case strings.HasPrefix(line, "SKI "):
+			// a pass, the color is whoever didn't move last
+			col := Black
+			if r.last == Black {
+				col = White
+			}
+			r.move(-1, -1, col)
+			moves++
+		}
+	}
+
+	if _, ok := r.root.Fields["PB"]; !ok && moves == 0 {
+		return nil, fmt.Errorf("no game found")
+	}
+	return r.root, nil
+}
+
+var (
+	ngfDateRegexp   = regexp.MustCompile(`(\d\d\d\d)(\d\d)(\d\d)`)
+	ngfMarginRegexp = regexp.MustCompile(`\d+(\.\d+)?`)
+)
+
+// ngfResult reads things like "White wins by resignation!"
+func ngfResult(s string) string {
+	lower := strings.ToLower(s)
+	winner := ""
+	if strings.Contains(lower, "white win") {
+		winner = "W"
+	} else if strings.Contains(lower, "black win") {
+		winner = "B"
+	} else {
+		return ""
+	}
+	if strings.Contains(lower, "resign") {
+		return winner + "+R"
+	}
+	if strings.Contains(lower, "time") {
+		return winner + "+T"
+	}
+	if m := ngfMarginRegexp.FindString(lower); m != "" {
+		return winner + "+" + m
+	}
+	return winner + "+"
+}
+
+func ParseNGF(data string) (*SGFNode, error) {
+	lines := splitLines(data)
+	if len(lines) < 12 {
+		return nil, fmt.Errorf("header too short")
+	}
+	for i := range lines {
+		lines[i] = strings.TrimSpace(lines[i])
+	}
+
+	r := newRecordBuilder()
+	size, err := strconv.Atoi(lines[1])
+	if err != nil {
+		return nil, fmt.Errorf("bad board size: %s", lines[1])
+	}
+	r.setSize(size)
+
+	r.set("GN", lines[0])
+
+	name, rank := splitRank(lines[2])
+	r.set("PW", name)
+	r.set("WR", rank)
+	name, rank = splitRank(lines[3])
+	r.set("PB", name)
+	r.set("BR", rank)
+	r.set("PC", lines[4])
+
+	if n, err := strconv.Atoi(lines[5]); err == nil {
+		r.handicap(n)
+	}
+	if komi, err := strconv.ParseFloat(lines[7], 64); err == nil {
+		r.set("KM", strconv.FormatFloat(komi, 'f', -1, 64))
+	}
+	if m := ngfDateRegexp.FindStringSubmatch(lines[8]); m != nil {
+		r.set("DT", fmt.Sprintf("%s-%s-%s", m[1], m[2], m[3]))
+	}
+	r.set("RE", ngfResult(lines[10]))
+
+	// PM <2 char move number> <color> <x> <y> <x> <y>
+	// coordinates start from 'B', anything outside the board is a pass
+	for _, line := range lines[12:] {
+		if !strings.HasPrefix(line, "PM") || len(line) < 7 {
+			continue
+		}
+		col := Black
+		if line[4] == 'W' {
+			col = White
+		}
+		x := int(line[5]) - 'B'
+		y := int(line[6]) - 'B'
+		r.move(x, y, col)
+	}
+	return r.root, nil
+}
+
+// ParseUGF reads the ini-style UGF and UGI formats
+func ParseUGF(data string) (*SGFNode, error) {
+	r := newRecordBuilder()
+	section := ""
+	header := make(map[string]string)
+	moves := [][]string{}
+
+	for _, raw := range splitLines(data) {
+		line := strings.TrimSpace(raw)
+		if line == "" {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line[1 : len(line)-1])
+			continue
+		}
+		switch section {
+		case "header":
+			if i := strings.Index(line, "="); i != -1 {
+				header[strings.ToLower(line[:i])] = line[i+1:]
+			}
+		case "data":
+			moves = append(moves, strings.Split(line, ","))
+		}
+	}
+
+	if size, err := strconv.Atoi(header["size"]); err == nil {
+		r.setSize(size)
+	}
+
+	first := func(s string) string {
+		return strings.Split(s, ",")[0]
+	}
+
+	r.set("GN", first(header["title"]))
+	r.set("PC", first(header["place"]))
+	r.set("DT", strings.ReplaceAll(first(header["date"]), "/", "-"))
+
+	for key, prefix := range map[string]string{"playerb": "B", "playerw": "W"} {
+		spl := strings.Split(header[key], ",")
+		r.set("P"+prefix, spl[0])
+		if len(spl) > 1 {
+			r.set(prefix+"R", spl[1])
+		}
+	}
+
+	// Hdcp=<handicap>,<komi>
+	hdcp := strings.Split(header["hdcp"], ",")
+	if len(hdcp) > 1 {
+		if komi, err := strconv.ParseFloat(hdcp[1], 64); err == nil {
+			r.set("KM", strconv.FormatFloat(komi, 'f', -1, 64))
+		}
+	}
+	if n, err := strconv.Atoi(hdcp[0]); err == nil && n > 1 {
+		r.set("HA", hdcp[0])
+	}
+
+	// Winner=<B or W>,<margin or C for resignation>
+	winner := strings.Split(header["winner"], ",")
+	if w := strings.ToUpper(winner[0]); w == "B" || w == "W" {
+		margin := ""
+		if len(winner) > 1 {
+			margin = strings.ToUpper(strings.TrimSpace(winner[1]))
+		}
+		if margin == "C" || margin == "R" {
+			margin = "R"
+		} else if _, err := strconv.ParseFloat(margin, 64); err != nil && margin != "T" {
+			margin = ""
+		}
+		r.set("RE", w+"+"+margin)
+	}
+
+	// rows count up from the bottom when the coordinates are IGS style
+	fromBottom := strings.ToUpper(first(header["coordinatetype"])) == "IGS"
+
+	// <coord>,<color><move number>,<time>
+	// move number 0 means the stone was placed before the game
+	for _, tokens := range moves {
+		if len(tokens) < 2 || len(tokens[0]) < 2 || len(tokens[1]) < 1 {
+			continue
+		}
+		x := int(tokens[0][0]) - 'A'
+		y := int(tokens[0][1]) - 'A'
+		if fromBottom {
+			y = r.size - 1 - y
+		}
+		col := Black
+		if tokens[1][0] == 'W' || tokens[1][0] == 'w' {
+			col = White
+		}
+		if tokens[1][1:] == "0" {
+			r.setup(x, y, col)
+		} else {
+			r.move(x, y, col)
+		}
+	}
+	return r.root, nil
+}
@@ -0,0 +1,181 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+const gibSample = `\HS
+\[GAMEINFOMAIN=GBKIND:3,GTYPE:0,GCDT:0,GTIME:0-0-0,GRLT:1,ZIPSU:35,DUMMY:0,GONGJE:65,\]
+\[GAMETAG=S1,R3,D0,G65,W1,Z35,T30-3-1200,C2015:06:02:11:24,I:player1,L:ccc\]
+\[GAMEBLACKNAME=blackplayer (5D)\]
+\[GAMEWHITENAME=whiteplayer (7D)\]
+\HE
+\GS
+2 1 0
+INI 0 1 0 &4
+STO 0 2 1 15 3
+STO 0 3 2 3 15
+SKI 0 4
+STO 0 5 2 15 15
+\GE
+`
+
+const ngfSample = `Friendly game
+19
+whiteplayer    3D*
+blackplayer    1D*
+www.cyberoro.com
+0
+0
+6.5
+20040525 [12:22]
+1
+White wins by resignation!
+3
+PMABBQEQE
+PMACWEQEQ
+PMADBQQQQ
+`
+
+const ugfSample = `[Header]
+Title=Test Game,1
+Date=2006/10/12,12:00
+Size=19
+Hdcp=0,6.5
+Winner=B,C
+PlayerB=blackplayer,2d,
+PlayerW=whiteplayer,3d,
+CoordinateType=IGS
+[Data]
+PP,B1,0
+DD,W2,0
+`
+
+func TestDetectFormat(t *testing.T) {
+	tests := map[string]backend.Format{
+		gibSample:        backend.GIBFormat,
+		ngfSample:        backend.NGFFormat,
+		ugfSample:        backend.UGFFormat,
+		"(;GM[1];B[aa])": backend.SGFFormat,
+	}
+	for input, format := range tests {
+		if f := backend.DetectFormat([]byte(input)); f != format {
+			t.Errorf("expected %v, got: %v", format, f)
+		}
+	}
+}
+
+func checkFields(t *testing.T, root *backend.SGFNode, expected map[string]string) {
+	for key, value := range expected {
+		if len(root.Fields[key]) != 1 || root.Fields[key][0] != value {
+			t.Errorf("expected %s[%s], got: %v", key, value, root.Fields[key])
+		}
+	}
+}
+
+func mainLine(root *backend.SGFNode) []*backend.SGFNode {
+	nodes := []*backend.SGFNode{}
+	cur := root
+	for len(cur.Down) > 0 {
+		cur = cur.Down[0]
+		nodes = append(nodes, cur)
+	}
+	return nodes
+}
+
+func TestGIB(t *testing.T) {
+	root, err := backend.ParseGIB(gibSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, root, map[string]string{
+		"PB": "blackplayer",
+		"BR": "5D",
+		"PW": "whiteplayer",
+		"WR": "7D",
+		"KM": "6.5",
+		"RE": "W+3.5",
+		"DT": "2015-06-02",
+	})
+	moves := mainLine(root)
+	if len(moves) != 4 {
+		t.Fatalf("expected 4 moves, got: %d", len(moves))
+	}
+	if moves[0].Fields["B"][0] != "pd" || moves[1].Fields["W"][0] != "dp" {
+		t.Errorf("wrong moves: %v %v", moves[0].Fields, moves[1].Fields)
+	}
+	if !moves[2].IsPass() || moves[2].Color() != backend.Black {
+		t.Errorf("expected black pass, got: %v", moves[2].Fields)
+	}
+}
+
+func TestNGF(t *testing.T) {
+	root, err := backend.ParseNGF(ngfSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, root, map[string]string{
+		"PB": "blackplayer",
+		"BR": "1D",
+		"PW": "whiteplayer",
+		"WR": "3D",
+		"KM": "6.5",
+		"RE": "W+R",
+		"DT": "2004-05-25",
+		"SZ": "19",
+	})
+	moves := mainLine(root)
+	if len(moves) != 3 {
+		t.Fatalf("expected 3 moves, got: %d", len(moves))
+	}
+	if moves[0].Fields["B"][0] != "pd" || moves[1].Fields["W"][0] != "dp" {
+		t.Errorf("wrong moves: %v %v", moves[0].Fields, moves[1].Fields)
+	}
+}
+
+func TestUGF(t *testing.T) {
+	root, err := backend.ParseUGF(ugfSample)
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkFields(t, root, map[string]string{
+		"GN": "Test Game",
+		"PB": "blackplayer",
+		"BR": "2d",
+		"PW": "whiteplayer",
+		"KM": "6.5",
+		"RE": "B+R",
+		"DT": "2006-10-12",
+	})
+	moves := mainLine(root)
+	if len(moves) != 2 {
+		t.Fatalf("expected 2 moves, got: %d", len(moves))
+	}
+	// rows count from the bottom
+	if moves[0].Fields["B"][0] != "pd" {
+		t.Errorf("expected B[pd], got: %v", moves[0].Fields)
+	}
+}
+
+func TestConvertLoads(t *testing.T) {
+	for _, input := range []string{gibSample, ngfSample, ugfSample} {
+		games, err := backend.GamesFromBytes([]byte(input))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err := backend.FromSGF(games[0]); err != nil {
+			t.Error(err)
+		}
+	}
+}
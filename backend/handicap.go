@@ -0,0 +1,184 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+handicap stones are setup (AB on the root), not moves
+fixed placement puts them on the star points right away,
+free placement lets black click them in one at a time
+either way HA records the handicap and PL[W] says white plays first
+*/
+
+// ValidateHandicap checks that n handicap stones can be placed on a
+// board of the given size, 0 meaning no handicap
+func ValidateHandicap(size, n int, free bool) error {
+	if n == 0 {
+		return nil
+	}
+	if n < 2 {
+		return fmt.Errorf("handicap must be at least 2")
+	}
+	if !free && HandicapPoints(size, n) == nil {
+		return fmt.Errorf("no fixed placement for %d stones on %dx%d", n, size, size)
+	}
+	if free && n >= size*size {
+		return fmt.Errorf("too many handicap stones")
+	}
+	return nil
+}
+
+// Handicap reads HA from the root, defaulting to 0
+func (s *State) Handicap() int {
+	if ha, ok := s.Root.Fields["HA"]; ok && len(ha) > 0 {
+		n, err := strconv.Atoi(ha[0])
+		if err == nil {
+			return n
+		}
+	}
+	return 0
+}
+
+// SetHandicap sets up a handicap game on a fresh root
+func (s *State) SetHandicap(n int, free bool) error {
+	if s.Current != s.Root || len(s.Root.Down) > 0 {
+		return fmt.Errorf("handicap can only be set at the start of a game")
+	}
+	if n == 0 {
+		return fmt.Errorf("handicap must be at least 2")
+	}
+	if err := ValidateHandicap(s.Size, n, free); err != nil {
+		return err
+	}
+
+	var points []*Coord
+	if !free {
+		points = HandicapPoints(s.Size, n)
+	}
+
+	s.Root.Fields["HA"] = []string{strconv.Itoa(n)}
+	s.Root.Fields["KM"] = []string{"0.5"}
+	delete(s.Root.Fields, "PL")
+
+	for _, c := range points {
+		s.AddRootStone(c, Black)
+	}
+
+	if free {
+		s.FreeHandicap = n
+	} else {
+		s.Root.Fields["PL"] = []string{"W"}
+	}
+	return nil
+}
+
+// AddRootStone adds a setup stone to the root node
+// it doesn't touch the rest of the tree, so it's only for a root
+// without children
+func (s *State) AddRootStone(c *Coord, col Color) *Diff {
+	key := "AB"
+	if col == White {
+		key = "AW"
+	}
+	s.Root.AddField(key, c.ToLetters())
+
+	cs := NewCoordSet()
+	cs.Add(c)
+	diff := NewDiff([]*StoneSet{NewStoneSet(cs, col)}, nil)
+	if s.Root.Diff == nil {
+		s.Root.Diff = NewDiff([]*StoneSet{}, []*StoneSet{})
+	}
+	s.Root.Diff.Add = append(s.Root.Diff.Add, diff.Add...)
+	s.Board.ApplyDiff(diff)
+	s.Root.ComputeHash()
+	return diff
+}
+
+// PlaceHandicapStone places one stone during free placement
+func (s *State) PlaceHandicapStone(c *Coord) (*Diff, error) {
+	if s.FreeHandicap <= 0 || s.Current != s.Root {
+		return nil, fmt.Errorf("not placing handicap stones")
+	}
+	// moves already in the tree were hashed and counted without it
+	if len(s.Root.Down) > 0 {
+		return nil, fmt.Errorf("handicap stones can't be placed once the game has moves")
+	}
+	if s.Board.Get(c) != NoColor {
+		return nil, nil
+	}
+	diff := s.AddRootStone(c, Black)
+	s.FreeHandicap--
+	if s.FreeHandicap == 0 {
+		s.Root.Fields["PL"] = []string{"W"}
+	}
+	return diff, nil
+}
+
+// HandicapPoints returns the standard (fixed) placement for n handicap
+// stones on a board of the given size, or nil if there isn't one
+func HandicapPoints(size, n int) []*Coord {
+	if n < 2 || n > MaxHandicap(size) {
+		return nil
+	}
+
+	// distance from the edge for the corner star points
+	d := 3
+	if size < 13 {
+		d = 2
+	}
+	lo := d
+	hi := size - 1 - d
+	mid := size / 2
+
+	// upper right, lower left, lower right, upper left
+	corners := []*Coord{{hi, lo}, {lo, hi}, {hi, hi}, {lo, lo}}
+	center := &Coord{mid, mid}
+	sides := []*Coord{{lo, mid}, {hi, mid}, {mid, lo}, {mid, hi}}
+
+	points := []*Coord{}
+	if n <= 4 {
+		return append(points, corners[:n]...)
+	}
+	points = append(points, corners...)
+	switch n {
+	case 5:
+		points = append(points, center)
+	case 6:
+		points = append(points, sides[:2]...)
+	case 7:
+		points = append(points, sides[:2]...)
+		points = append(points, center)
+	case 8:
+		points = append(points, sides...)
+	case 9:
+		points = append(points, sides...)
+		points = append(points, center)
+	}
+	return points
+}
+
+// MaxHandicap is the most fixed handicap stones a board size supports
+func MaxHandicap(size int) int {
+	if size < 7 {
+		return 0
+	}
+	if size%2 == 0 {
+		return 4
+	}
+	if size < 9 {
+		return 5
+	}
+	return 9
+}
@@ -0,0 +1,87 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+)
+
+// HistoryEntry is the position reached at a node on the current path
+type HistoryEntry struct {
+	Node *TreeNode
+	Key  string
+}
+
+// History walks from the current node back to the root, undoing diffs
+// on a copy of the board, and returns the position at each node
+// the first entry is the root and the last is the current node
+// (so moves further down can be appended)
+func (s *State) History() []*HistoryEntry {
+	board := s.Board.Copy()
+	history := []*HistoryEntry{}
+	for node := s.Current; node != nil; node = node.Up {
+		history = append(history, &HistoryEntry{node, board.Key()})
+		board.ApplyDiff(node.Diff.Invert())
+	}
+	for i, j := 0, len(history)-1; i < j; i, j = i+1, j-1 {
+		history[i], history[j] = history[j], history[i]
+	}
+	return history
+}
+
+// KoViolation checks whether col playing at coord would repeat an
+// earlier position under the board's ruleset
+// it returns nil if the move is fine (as far as ko is concerned)
+func (s *State) KoViolation(coord *Coord, col Color) error {
+	return koViolation(s.Board, s.History(), coord, col)
+}
+
+// koViolation is KoViolation for any board, given the history leading
+// to it (most recent last)
+func koViolation(b *Board, history []*HistoryEntry, coord *Coord, col Color) error {
+	board := b.Copy()
+	diff := board.Move(coord, col)
+	if diff == nil {
+		// illegal for some other reason
+		return nil
+	}
+	key := board.Key()
+
+	rule := b.Rules.Ko()
+
+	if rule == SimpleKo {
+		// only the immediate recapture: a single stone captured,
+		// going back to the position before the opponent's last move
+		captured := 0
+		for _, r := range diff.Remove {
+			captured += len(r.Coords)
+		}
+		if captured == 1 && len(history) > 1 && history[len(history)-2].Key == key {
+			return fmt.Errorf("illegal ko recapture at %s, play elsewhere first", coord.ToLetters())
+		}
+		return nil
+	}
+
+	// repeats are most likely recent, so look back from the end
+	for i := len(history) - 1; i >= 0; i-- {
+		h := history[i]
+		if h.Key != key {
+			continue
+		}
+		// situational superko only cares about positions with the
+		// same player to move, i.e. positions col just moved into
+		if rule == SituationalSuperko && h.Node.Color != col {
+			continue
+		}
+		return fmt.Errorf("move at %s repeats an earlier position (%s)", coord.ToLetters(), rule)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+// black has just taken the ko at cb, white retaking at bb is illegal
+const koMoves = ";B[ba];W[ca];B[ab];W[db];B[bc];W[cc];B[pp];W[bb];B[cb]"
+
+func koState(t *testing.T, rules, extra string) *backend.State {
+	s, err := backend.FromSGF("(;GM[1]SZ[19]RU[" + rules + "]" + koMoves + extra + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	return s
+}
+
+func TestSimpleKo(t *testing.T) {
+	s := koState(t, "Japanese", "")
+	if err := s.KoViolation(&backend.Coord{1, 1}, backend.White); err == nil {
+		t.Errorf("expected immediate ko recapture to be illegal")
+	}
+	if err := s.KoViolation(&backend.Coord{15, 3}, backend.White); err != nil {
+		t.Errorf("expected ordinary move to be fine, got: %v", err)
+	}
+
+	// after a ko threat and answer, the recapture is fine
+	s = koState(t, "Japanese", ";W[pd];B[dd]")
+	if err := s.KoViolation(&backend.Coord{1, 1}, backend.White); err != nil {
+		t.Errorf("expected ko recapture after threat to be legal, got: %v", err)
+	}
+}
+
+func TestReplayKo(t *testing.T) {
+	// taking away the ko threat and its answer
+	// makes the recapture at bb illegal
+	s := koState(t, "Japanese", ";W[pd];B[dd];W[bb]")
+	answer := s.Current.Up
+	if _, err := s.DeleteNode(answer.Up.Index); err != nil {
+		t.Fatal(err)
+	}
+	report, err := s.DeleteNode(answer.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(report.Dropped) != 1 || report.Dropped[0].Reason != "ko" {
+		t.Errorf("expected the recapture to be dropped, got: %v", report.Dropped)
+	}
+	s.FastForward()
+	if s.Current.XY.X != 2 || s.Current.XY.Y != 1 {
+		t.Errorf("expected the line to end at cb, got: %s", s.Current.XY.ToLetters())
+	}
+}
+
+func TestPositionalSuperko(t *testing.T) {
+	s := koState(t, "Chinese", "")
+	if err := s.KoViolation(&backend.Coord{1, 1}, backend.White); err == nil {
+		t.Errorf("expected ko recapture to repeat a position")
+	}
+
+	// passing doesn't change the position
+	// so after two passes retaking is still a repetition
+	s = koState(t, "Chinese", ";W[];B[]")
+	if err := s.KoViolation(&backend.Coord{1, 1}, backend.White); err == nil {
+		t.Errorf("expected ko recapture after passes to repeat a position")
+	}
+}
+
+func TestSituationalSuperko(t *testing.T) {
+	// single stone suicide leaves the position unchanged
+	// but with the other player to move
+	moves := ";B[ba];W[];B[ab]"
+	s, err := backend.FromSGF("(;GM[1]SZ[19]RU[NZ]" + moves + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	if err := s.KoViolation(&backend.Coord{0, 0}, backend.White); err != nil {
+		t.Errorf("expected single stone suicide to be allowed, got: %v", err)
+	}
+
+	s, err = backend.FromSGF("(;GM[1]SZ[19]RU[Tromp-Taylor]" + moves + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	if err := s.KoViolation(&backend.Coord{0, 0}, backend.White); err == nil {
+		t.Errorf("expected single stone suicide to repeat a position")
+	}
+}
@@ -12,6 +12,7 @@ package main
 
 import (
 	"fmt"
+	"sort"
 	"strings"
 )
 
@@ -70,44 +71,57 @@ func (n *SGFNode) Coord() *Coord {
 }
 
 func (n *SGFNode) ToSGF(root bool) string {
-	result := ""
+	var sb strings.Builder
+	n.writeSGF(&sb, root)
+	return sb.String()
+}
+
+func (n *SGFNode) writeSGF(sb *strings.Builder, root bool) {
 	if root {
-		result += "("
+		sb.WriteByte('(')
 	}
-	result += ";"
+	sb.WriteByte(';')
 	for field, values := range n.Fields {
-		result += field
+		sb.WriteString(field)
 		for _, value := range values {
-			result += "["
-			result += strings.ReplaceAll(value, "]", "\\]")
-			result += "]"
+			sb.WriteByte('[')
+			sb.WriteString(strings.ReplaceAll(value, "]", "\\]"))
+			sb.WriteByte(']')
 		}
 	}
 
 	for _, d := range n.Down {
 		if len(n.Down) > 1 {
-			result += "(" + d.ToSGF(false) + ")"
+			sb.WriteByte('(')
+			d.writeSGF(sb, false)
+			sb.WriteByte(')')
 		} else {
-			result += d.ToSGF(false)
+			d.writeSGF(sb, false)
 		}
 	}
 	if root {
-		result += ")"
+		sb.WriteByte(')')
 	}
-	return result
 }
 
 func NewSGFNode(fields map[string][]string, index int) *SGFNode {
 	return &SGFNode{fields, []*SGFNode{}, index}
 }
 
+// Parser works directly over the bytes of the input
+// fields and keys are sliced out of the input wherever possible
+// so that long comments don't cost an allocation per character
 type Parser struct {
-	Text  string
+	Text  []byte
 	Index int
 }
 
 func NewParser(text string) *Parser {
-	return &Parser{text, 0}
+	return &Parser{[]byte(text), 0}
+}
+
+func NewParserBytes(data []byte) *Parser {
+	return &Parser{data, 0}
 }
 
 func (p *Parser) Parse() (*SGFNode, error) {
@@ -124,18 +138,36 @@ func (p *Parser) Parse() (*SGFNode, error) {
 	}
 }
 
-func (p *Parser) SkipWhitespace() {
+// ParseCollection reads every game tree in the input
+// e.g. (;GM[1]...)(;GM[1]...)
+// anything after the last game tree is ignored
+func (p *Parser) ParseCollection() ([]*SGFNode, error) {
+	roots := []*SGFNode{}
 	for {
-		if IsWhitespace(p.peek(0)) {
-			p.read()
-		} else {
+		p.SkipWhitespace()
+		if p.peek(0) != '(' {
 			break
 		}
+		root, err := p.Parse()
+		if err != nil {
+			return nil, fmt.Errorf("game %d: %s", len(roots)+1, err)
+		}
+		roots = append(roots, root)
+	}
+	if len(roots) == 0 {
+		return nil, fmt.Errorf("unexpected %c", p.peek(0))
+	}
+	return roots, nil
+}
+
+func (p *Parser) SkipWhitespace() {
+	for p.Index < len(p.Text) && IsWhitespace(p.Text[p.Index]) {
+		p.Index++
 	}
 }
 
 func (p *Parser) ParseKey() (string, error) {
-	s := ""
+	start := p.Index
 	for {
 		c := p.peek(0)
 		if c == 0 {
@@ -143,25 +175,45 @@ func (p *Parser) ParseKey() (string, error) {
 		} else if c < 'A' || c > 'Z' {
 			break
 		}
-		s += string([]byte{p.read()})
+		p.Index++
 	}
-	return s, nil
+	return string(p.Text[start:p.Index]), nil
 }
 
 func (p *Parser) ParseField() (string, error) {
-	s := ""
+	start := p.Index
+
+	// fast path: no escaped brackets, so the field is just a slice
+	for i := start; i < len(p.Text); i++ {
+		t := p.Text[i]
+		if t == ']' {
+			p.Index = i + 1
+			return string(p.Text[start:i]), nil
+		} else if t == '\\' && i+1 < len(p.Text) && p.Text[i+1] == ']' {
+			break
+		}
+	}
+
+	// slow path: copy runs between escapes into a builder
+	var sb strings.Builder
+	run := start
 	for {
-		t := p.read()
-		if t == 0 {
+		if p.Index >= len(p.Text) {
 			return "", fmt.Errorf("bad field")
-		} else if t == ']' {
+		}
+		t := p.Text[p.Index]
+		if t == ']' {
+			sb.Write(p.Text[run:p.Index])
+			p.Index++
 			break
-		} else if t == '\\' && p.peek(0) == ']' {
-			t = p.read()
+		} else if t == '\\' && p.peek(1) == ']' {
+			sb.Write(p.Text[run:p.Index])
+			p.Index++
+			run = p.Index
 		}
-		s = fmt.Sprintf("%s%c", s, t)
+		p.Index++
 	}
-	return s, nil
+	return sb.String(), nil
 }
 
 func (p *Parser) ParseNodes() ([]*SGFNode, error) {
@@ -293,11 +345,104 @@ func (p *Parser) peek(n int) byte {
 	return p.Text[p.Index+n]
 }
 
+// SplitCollection separates a collection into one sgf per game tree
+// if there's only one game the input is returned as is
+// the error says which game didn't parse
+func SplitCollection(sgf string) ([]string, error) {
+	p := NewParser(sgf)
+	roots, err := p.ParseCollection()
+	if err != nil {
+		return nil, err
+	}
+	if len(roots) == 1 {
+		return []string{sgf}, nil
+	}
+	games := []string{}
+	for _, root := range roots {
+		games = append(games, root.ToSGF(true))
+	}
+	return games, nil
+}
+
+type GameInfo struct {
+	Index  int    `json:"index"`
+	Name   string `json:"name"`
+	Black  string `json:"black"`
+	White  string `json:"white"`
+	Date   string `json:"date"`
+	Result string `json:"result"`
+}
+
+// GameInfos gathers the names of each game so that the uploader
+// of a collection can choose which one to open
+func GameInfos(sgfs []string) []*GameInfo {
+	first := func(fields map[string][]string, key string) string {
+		if len(fields[key]) == 0 {
+			return ""
+		}
+		return fields[key][0]
+	}
+	infos := []*GameInfo{}
+	for i, sgf := range sgfs {
+		info := &GameInfo{Index: i}
+		p := NewParser(sgf)
+		root, err := p.Parse()
+		if err == nil {
+			info.Name = first(root.Fields, "GN")
+			info.Black = first(root.Fields, "PB")
+			info.White = first(root.Fields, "PW")
+			info.Date = first(root.Fields, "DT")
+			info.Result = first(root.Fields, "RE")
+		}
+		if info.Name == "" && (info.Black != "" || info.White != "") {
+			info.Name = fmt.Sprintf("%s vs %s", info.Black, info.White)
+		}
+		if info.Name == "" {
+			info.Name = fmt.Sprintf("Game %d", i+1)
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// SkippedSGF records a file that was left out of a merge
+type SkippedSGF struct {
+	// -1 for a file that couldn't be read at all
+	Index  int    `json:"index"`
+	Reason string `json:"reason"`
+	// the uploaded file it came from, if known
+	File string `json:"file"`
+}
+
 func Merge(sgfs []string) string {
+	merged, _ := MergeWithReport(sgfs)
+	return merged
+}
+
+// MergeWithReport combines several sgfs into one tree
+// moves that the games have in common are shared, and each game
+// branches off where it diverges from the games before it
+// sgfs that can't be merged are reported instead of silently dropped
+func MergeWithReport(sgfs []string) (string, []*SkippedSGF) {
+	// each sgf might itself be a collection
+	// (one that doesn't parse is kept whole, to be reported below)
+	games := []string{}
+	reasons := make(map[int]string)
+	for _, sgf := range sgfs {
+		split, err := SplitCollection(sgf)
+		if err != nil {
+			reasons[len(games)] = err.Error()
+			split = []string{sgf}
+		}
+		games = append(games, split...)
+	}
+	sgfs = games
+
+	skipped := []*SkippedSGF{}
 	if len(sgfs) == 0 {
-		return ""
+		return "", skipped
 	} else if len(sgfs) == 1 {
-		return sgfs[0]
+		return sgfs[0], skipped
 	}
 
 	size := ""
@@ -312,11 +457,15 @@ func Merge(sgfs []string) string {
 
 	newRoot := NewSGFNode(fields, 0)
 
-	for _, sgf := range sgfs {
+	for i, sgf := range sgfs {
+		if reason, ok := reasons[i]; ok {
+			skipped = append(skipped, &SkippedSGF{i, reason, ""})
+			continue
+		}
 		p := NewParser(sgf)
 		root, err := p.Parse()
 		if err != nil {
-			// on error, just continue
+			skipped = append(skipped, &SkippedSGF{i, err.Error(), ""})
 			continue
 		}
 		eachSize := ""
@@ -332,71 +481,178 @@ func Merge(sgfs []string) string {
 			size = eachSize
 		}
 
-		// if not all the sgfs are the same size, just return the first one?
+		// the first sgf decides the size
 		if size != eachSize {
-			return sgfs[0]
+			reason := fmt.Sprintf("board size %s does not match %s", eachSize, size)
+			skipped = append(skipped, &SkippedSGF{i, reason, ""})
+			continue
 		}
 
-		_, hasB := root.Fields["B"]
-		_, hasW := root.Fields["W"]
-		_, hasAB := root.Fields["AB"]
-		_, hasAW := root.Fields["AW"]
+		// game info gets left in a comment where the game diverges
+		info := []string{}
+		for _, key := range []string{"GN", "PB", "PW", "RE", "KM", "DT"} {
+			if len(root.Fields[key]) == 0 {
+				continue
+			}
+			value := root.Fields[key][0]
+			info = append(info, fmt.Sprintf("%s: %s", key, value))
+		}
 
-		if hasB || hasW || hasAB || hasAW {
-			// strip fields and save the node
-			for _, key := range []string{"RU", "SZ", "KM", "TM", "OT"} {
+		starts := root.Down
+		if mergeKey(root) != "" {
+			// the root has moves or setup of its own, so keep it
+			// minus the fields that only belong in the real root
+			for _, key := range []string{
+				"GM", "FF", "CA", "AP", "ST", "RU", "SZ", "KM", "TM", "OT",
+				"GN", "PB", "PW", "BR", "WR", "RE", "DT", "EV", "RO", "PC"} {
 				delete(root.Fields, key)
 			}
-			newRoot.Down = append(newRoot.Down, root)
-		} else {
-			// otherwise save all the children
-			for _, d := range root.Down {
-				if _, ok := d.Fields["C"]; !ok {
-					d.Fields["C"] = []string{}
+			starts = []*SGFNode{root}
+		}
+
+		for _, start := range starts {
+			d := graft(newRoot, start)
+			if len(info) == 0 {
+				continue
+			}
+			// C only has one value, so the info goes on the end of it
+			comment := strings.Join(append(d.Fields["C"], info...), "\n")
+			d.Fields["C"] = []string{comment}
+		}
+	}
+
+	// nothing could be merged, let the first sgf speak for itself
+	if size == "" {
+		return sgfs[0], skipped
+	}
+
+	newRoot.Fields["SZ"] = []string{size}
+	return newRoot.ToSGF(true), skipped
+}
+
+// mergeKey identifies the move or setup of a node
+// nodes with neither get an empty key and are never shared
+func mergeKey(n *SGFNode) string {
+	key := ""
+	for _, k := range []string{"B", "W", "AB", "AW", "AE"} {
+		values, ok := n.Fields[k]
+		if !ok {
+			continue
+		}
+		sorted := make([]string, len(values))
+		copy(sorted, values)
+		sort.Strings(sorted)
+		key += k + "[" + strings.Join(sorted, "][") + "]"
+	}
+	return key
+}
+
+// mergeFields adds what node says to match, which plays the same move
+// comments are joined into match's single comment (unless it already
+// has them), point lists get the points match doesn't have, and
+// anything else match already has is kept
+func mergeFields(match, node *SGFNode) {
+	for key, values := range node.Fields {
+		old, ok := match.Fields[key]
+		switch {
+		case !ok:
+			match.Fields[key] = append([]string{}, values...)
+		case key == "C" || key == "GC":
+			comment := strings.Join(old, "\n")
+			for _, c := range values {
+				if comment == "" {
+					comment = c
+				} else if !strings.Contains(comment, c) {
+					comment += "\n" + c
 				}
-				for _, key := range []string{"PB", "PW", "RE", "KM", "DT"} {
-					if len(root.Fields[key]) == 0 {
-						continue
+			}
+			match.Fields[key] = []string{comment}
+		case pointList(key):
+			for _, v := range values {
+				found := false
+				for _, o := range old {
+					if o == v {
+						found = true
+						break
 					}
-					value := root.Fields[key][0]
-					d.Fields["C"] = append(d.Fields["C"], fmt.Sprintf("%s: %s", key, value))
 				}
-				if len(d.Fields["C"]) == 0 {
-					delete(d.Fields, "C")
+				if !found {
+					match.Fields[key] = append(match.Fields[key], v)
 				}
-				newRoot.Down = append(newRoot.Down, d)
 			}
 		}
 	}
-
-	newRoot.Fields["SZ"] = []string{size}
-	return newRoot.ToSGF(true)
 }
 
-func Validate(node *SGFNode) (*SGFNode, error) {
-	fields := make(map[string][]string)
-	for key, value := range node.Fields {
-		if (key == "B" || key == "W") && len(value) == 1 && value[0] == "tt" {
-			fields[key] = []string{""}
-		} else {
-			fields[key] = value
+// pointList says whether a property is a list of points (or of marks
+// on points) that can be combined from two nodes
+func pointList(key string) bool {
+	for _, k := range markKeys {
+		if k == key {
+			return true
 		}
 	}
+	switch key {
+	case "AR", "LN", "DD", "SL", "TB", "TW", CreditKey:
+		return true
+	}
+	return false
+}
 
-	down := []*SGFNode{}
-	for _, d := range node.Down {
-		e, err := Validate(d)
-		if err != nil {
-			return nil, err
+// graft merges the line starting at node underneath parent,
+// reusing children that play the same move
+// it returns the node where the line diverged from the existing tree
+// (or the last node, if the whole line was already there)
+func graft(parent, node *SGFNode) *SGFNode {
+	for {
+		key := mergeKey(node)
+		var match *SGFNode
+		if key != "" {
+			for _, d := range parent.Down {
+				if mergeKey(d) == key {
+					match = d
+					break
+				}
+			}
 		}
-		down = append(down, e)
-	}
 
-	n := &SGFNode{
-		Fields: fields,
-		Down:   down,
-		Index:  node.Index,
+		if match == nil {
+			parent.Down = append(parent.Down, node)
+			return node
+		}
+
+		mergeFields(match, node)
+
+		if len(node.Down) == 0 {
+			return match
+		}
+
+		// side variations get merged in too
+		for _, v := range node.Down[1:] {
+			graft(match, v)
+		}
+
+		parent = match
+		node = node.Down[0]
 	}
+}
 
-	return n, nil
+// Validate normalizes the tree in place
+// currently that just means turning [tt] passes into []
+func Validate(node *SGFNode) (*SGFNode, error) {
+	stack := []*SGFNode{node}
+	for len(stack) > 0 {
+		i := len(stack) - 1
+		cur := stack[i]
+		stack = stack[:i]
+
+		for _, key := range []string{"B", "W"} {
+			value, ok := cur.Fields[key]
+			if ok && len(value) == 1 && value[0] == "tt" {
+				cur.Fields[key] = []string{""}
+			}
+		}
+		stack = append(stack, cur.Down...)
+	}
+	return node, nil
 }
@@ -13,6 +13,7 @@ package main_test
 import (
 	"fmt"
 	backend "github.com/jarednogo/board/backend"
+	"strings"
 	"testing"
 )
 
@@ -115,3 +116,318 @@ func TestPass(t *testing.T) {
 		t.Errorf("error in reading [tt] pass")
 	}
 }
+
+var escapeTests = []struct {
+	input string
+	value string
+}{
+	{"(;C[a\\]b])", "a]b"},
+	{"(;C[\\]])", "]"},
+	{"(;C[a\\b])", "a\\b"},
+	{"(;C[a\\]b\\]c])", "a]b]c"},
+	{"(;C[])", ""},
+}
+
+func TestParseEscapes(t *testing.T) {
+	for _, tt := range escapeTests {
+		t.Run(tt.input, func(t *testing.T) {
+			root, err := backend.NewParser(tt.input).Parse()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if root.Fields["C"][0] != tt.value {
+				t.Errorf("expected %q, got: %q", tt.value, root.Fields["C"][0])
+			}
+		})
+	}
+}
+
+func TestParseUnterminated(t *testing.T) {
+	for _, input := range []string{"(;C[abc", "(;C[abc\\]", "(;GM[1]", "(;GM"} {
+		if _, err := backend.NewParser(input).Parse(); err == nil {
+			t.Errorf("expected error parsing %q", input)
+		}
+	}
+}
+
+// bigSGF generates a game in the style of an ai analysis file:
+// every move has a long comment and every tenth move has a short variation
+func bigSGF(n int) string {
+	letters := "abcdefghijklmnopqrs"
+	comment := strings.Repeat("winrate 52.3% score +1.5 visits 1000 [pv\\] ", 20)
+	var sb strings.Builder
+	sb.WriteString("(;GM[1]FF[4]SZ[19]")
+	for i := 0; i < n; i++ {
+		col := "B"
+		if i%2 == 1 {
+			col = "W"
+		}
+		x := letters[i%19]
+		y := letters[(i/19)%19]
+		if i%10 == 0 {
+			fmt.Fprintf(&sb, "(;%s[%c%c]C[%s])", col, y, x, comment)
+		}
+		fmt.Fprintf(&sb, ";%s[%c%c]C[%s]", col, x, y, comment)
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func TestParseBig(t *testing.T) {
+	root, err := backend.NewParser(bigSGF(1000)).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	count := 0
+	stack := []*backend.SGFNode{root}
+	for len(stack) > 0 {
+		cur := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+		count++
+		stack = append(stack, cur.Down...)
+	}
+	if count != 1101 {
+		t.Errorf("expected 1101 nodes, got: %d", count)
+	}
+}
+
+func benchmarkParse(b *testing.B, n int) {
+	sgf := bigSGF(n)
+	b.SetBytes(int64(len(sgf)))
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		p := backend.NewParser(sgf)
+		if _, err := p.Parse(); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkParse1k(b *testing.B)  { benchmarkParse(b, 1000) }
+func BenchmarkParse10k(b *testing.B) { benchmarkParse(b, 10000) }
+func BenchmarkParse50k(b *testing.B) { benchmarkParse(b, 50000) }
+
+func BenchmarkToSGF10k(b *testing.B) {
+	root, err := backend.NewParser(bigSGF(10000)).Parse()
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		root.ToSGF(true)
+	}
+}
+
+// bigGame generates a legal tree: each variation plays stones on
+// every other point so that no two stones ever touch
+func bigGame(variations int) string {
+	letters := "abcdefghijklmnopqrs"
+	var sb strings.Builder
+	sb.WriteString("(;GM[1]FF[4]SZ[19]")
+	for v := 0; v < variations; v++ {
+		sb.WriteString("(")
+		move := 0
+		for i := 0; i < 361; i++ {
+			x := (i + v) % 19
+			y := i / 19
+			if (x+y)%2 != 0 {
+				continue
+			}
+			col := "B"
+			if move%2 == 1 {
+				col = "W"
+			}
+			fmt.Fprintf(&sb, ";%s[%c%c]C[move %d]", col, letters[x], letters[y], move)
+			move++
+		}
+		sb.WriteString(")")
+	}
+	sb.WriteString(")")
+	return sb.String()
+}
+
+func BenchmarkFromSGF(b *testing.B) {
+	sgf := bigGame(50)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := backend.FromSGF(sgf); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestParseCollection(t *testing.T) {
+	input := "(;GN[first];B[aa])\n(;PB[x]PW[y];B[bb])  (;B[cc])\n"
+	roots, err := backend.NewParser(input).ParseCollection()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(roots) != 3 {
+		t.Fatalf("expected 3 games, got: %d", len(roots))
+	}
+
+	games, err := backend.SplitCollection(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(games) != 3 {
+		t.Fatalf("expected 3 games, got: %d", len(games))
+	}
+
+	infos := backend.GameInfos(games)
+	names := []string{"first", "x vs y", "Game 3"}
+	for i, info := range infos {
+		if info.Name != names[i] {
+			t.Errorf("expected name %s, got: %s", names[i], info.Name)
+		}
+	}
+
+	// a collection merges as separate branches
+	root, err := backend.NewParser(backend.Merge([]string{input})).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Down) != 3 {
+		t.Errorf("expected 3 children, got: %d", len(root.Down))
+	}
+}
+
+func TestSplitSingle(t *testing.T) {
+	input := "(;GM[1];B[aa])"
+	games, err := backend.SplitCollection(input)
+	if err != nil || len(games) != 1 || games[0] != input {
+		t.Errorf("single game should be returned unchanged")
+	}
+}
+
+func TestSplitBadGame(t *testing.T) {
+	// the second game is never closed
+	input := "(;GM[1];B[aa])(;GM[1];B[bb]"
+	if _, err := backend.SplitCollection(input); err == nil || !strings.Contains(err.Error(), "game 2") {
+		t.Errorf("expected an error for game 2, got: %v", err)
+	}
+
+	_, skipped := backend.MergeWithReport([]string{"(;GM[1];B[cc])", input})
+	if len(skipped) != 1 || skipped[0].Index != 1 || !strings.Contains(skipped[0].Reason, "game 2") {
+		t.Errorf("expected the collection to be reported, got: %v", skipped)
+	}
+
+	// a failed upload doesn't leave a collection behind
+	room := backend.NewRoom()
+	room.UploadGames([]string{"(;GM[1];B[aa])", "(;GM[1];B[bb])"})
+	if evt := room.UploadGames([]string{"(;GM[1];B[aa]"}); evt.Event != "error" {
+		t.Fatalf("expected the upload to fail, got: %v", evt)
+	}
+	if evt := room.HandleChooseGame(&backend.EventJSON{"choose_game", 0.0, 0, ""}); evt.Event != "error" {
+		t.Errorf("expected no collection after a failed upload")
+	}
+}
+
+func TestMergePrefix(t *testing.T) {
+	sgfs := []string{
+		"(;PB[a]PW[b];B[pd];W[dd];B[pp];W[dp])",
+		"(;PB[c]PW[d];B[pd];W[dd];B[dp])",
+		"(;PB[e]PW[f];B[pd];W[dc]C[hi])",
+	}
+	merged, skipped := backend.MergeWithReport(sgfs)
+	if len(skipped) != 0 {
+		t.Errorf("expected nothing skipped, got: %d", len(skipped))
+	}
+	root, err := backend.NewParser(merged).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// everything shares B[pd]
+	if len(root.Down) != 1 {
+		t.Fatalf("expected 1 child of root, got: %d", len(root.Down))
+	}
+	pd := root.Down[0]
+	if len(pd.Down) != 2 {
+		t.Fatalf("expected 2 children after B[pd], got: %d", len(pd.Down))
+	}
+
+	// the third game diverges at W[dc]
+	dc := pd.Down[1]
+	if dc.Fields["W"][0] != "dc" {
+		t.Errorf("expected W[dc], got: %v", dc.Fields)
+	}
+	if len(dc.Fields["C"]) != 1 || dc.Fields["C"][0] != "hi\nPB: e\nPW: f" {
+		t.Errorf("expected game info in the comment at divergence, got: %q", dc.Fields["C"])
+	}
+
+	// the first two share W[dd] and split after it
+	dd := pd.Down[0]
+	if len(dd.Down) != 2 {
+		t.Errorf("expected 2 children after W[dd], got: %d", len(dd.Down))
+	}
+}
+
+func TestMergeSharedNode(t *testing.T) {
+	sgfs := []string{
+		"(;GM[1];B[pd]C[one]TR[aa]N[first])",
+		"(;GM[1];B[pd]C[two]TR[aa][bb]SQ[cc]N[second])",
+	}
+	merged, _ := backend.MergeWithReport(sgfs)
+	root, err := backend.NewParser(merged).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Down) != 1 {
+		t.Fatalf("expected 1 child of root, got: %d", len(root.Down))
+	}
+	pd := root.Down[0]
+	if c := pd.Fields["C"]; len(c) != 1 || !strings.HasPrefix(c[0], "one\ntwo") {
+		t.Errorf("expected the comments joined in one value, got: %q", c)
+	}
+	if len(pd.Fields["TR"]) != 2 || len(pd.Fields["SQ"]) != 1 {
+		t.Errorf("expected the marks of both games, got: %v %v", pd.Fields["TR"], pd.Fields["SQ"])
+	}
+	if n := pd.Fields["N"]; len(n) != 1 || n[0] != "first" {
+		t.Errorf("expected the first game's name, got: %v", n)
+	}
+}
+
+func TestMergeReport(t *testing.T) {
+	sgfs := []string{
+		"(;SZ[19];B[aa])",
+		"(;SZ[9];B[aa])",
+		"(;B[aa]",
+		"(;B[bb])",
+	}
+	merged, skipped := backend.MergeWithReport(sgfs)
+	if len(skipped) != 2 {
+		t.Fatalf("expected 2 skipped, got: %d", len(skipped))
+	}
+	if skipped[0].Index != 1 || skipped[1].Index != 2 {
+		t.Errorf("wrong sgfs skipped: %d, %d", skipped[0].Index, skipped[1].Index)
+	}
+	root, err := backend.NewParser(merged).Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(root.Down) != 2 {
+		t.Errorf("expected 2 children, got: %d", len(root.Down))
+	}
+}
+
+func TestUploadReport(t *testing.T) {
+	room := backend.NewRoom()
+	files := []*backend.NamedFile{
+		{"a.sgf", []byte("(;GM[1]SZ[19];B[pd])")},
+		{"b.gib", []byte("\\HS\nSTO 0 1\n")},
+		{"c.sgf", []byte("(;GM[1]SZ[9];B[cc])")},
+	}
+	rejected := []*backend.SkippedSGF{{-1, "bad base64", "d.sgf"}}
+	room.UploadFiles(files, rejected)
+
+	skipped := room.Skipped()
+	if len(skipped) != 3 {
+		t.Fatalf("expected 3 files in the report, got: %d", len(skipped))
+	}
+	for i, file := range []string{"d.sgf", "b.gib", "c.sgf"} {
+		if skipped[i].File != file || skipped[i].Reason == "" {
+			t.Errorf("expected %s with a reason, got: %s %q", file, skipped[i].File, skipped[i].Reason)
+		}
+	}
+}
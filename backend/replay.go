@@ -0,0 +1,125 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+/*
+replaying works a branch out again from the position above it, so every
+diff (captures, setup), capture count and hash matches the new context
+
+it's used for pasting, and for editing a node in the middle of the tree,
+where everything below the edit has to be replayed
+
+a move that can't be played any more (the point is taken, it's suicide,
+or it breaks the ko rule) is dropped along with everything after it,
+and the report says what was dropped
+*/
+
+type ReplayReport struct {
+	Kept    int            `json:"kept"`
+	Dropped []*DroppedNode `json:"dropped"`
+}
+
+type DroppedNode struct {
+	Coord  *Coord `json:"coord"`
+	Color  Color  `json:"color"`
+	Reason string `json:"reason"`
+	// how many nodes after this one went with it
+	Following int `json:"following"`
+}
+
+func NewReplayReport() *ReplayReport {
+	return &ReplayReport{0, []*DroppedNode{}}
+}
+
+// Paste replays a branch under the current node
+// the branch is used as is, so pass in a copy
+func (s *State) Paste(branch *TreeNode) *ReplayReport {
+	// give the branch indexes
+	Fmap(func(n *TreeNode) {
+		i := s.GetNextIndex()
+		n.Index = i
+		s.track(n)
+	}, branch)
+
+	report := NewReplayReport()
+	if s.replay(branch, s.Current, s.Board.Copy(), s.History(), report) {
+		s.Current.Down = append(s.Current.Down, branch)
+	}
+	return report
+}
+
+// replayReason says why col can't play at coord, or "" if it can
+func replayReason(board *Board, history []*HistoryEntry, coord *Coord, col Color) string {
+	if board.Get(coord) != NoColor {
+		return "occupied"
+	} else if !board.Legal(coord, col) {
+		return "suicide"
+	} else if err := koViolation(board, history, coord, col); err != nil {
+		return "ko"
+	}
+	return ""
+}
+
+// replay works out n again as a child of parent, whose position is on
+// board, and then everything below it
+// it returns false if n was dropped, in which case n and its subtree
+// are gone from s.Nodes
+// the caller puts n in parent.Down
+func (s *State) replay(n, parent *TreeNode, board *Board, history []*HistoryEntry, report *ReplayReport) bool {
+	// replaying can drop nodes, which leaves the layout stale
+	s.changed()
+	var diff *Diff
+	if n.XY != nil {
+		if reason := replayReason(board, history, n.XY, n.Color); reason != "" {
+			following := -1
+			Fmap(func(m *TreeNode) {
+				following++
+				delete(s.Nodes, m.Index)
+			}, n)
+			report.Dropped = append(report.Dropped, &DroppedNode{n.XY, n.Color, reason, following})
+			return false
+		}
+		diff = board.Move(n.XY, n.Color)
+	} else if n.Color != Black && n.Color != White {
+		// setup node (passes have no diff)
+		diff = SetupDiff(board, n.Fields)
+		board.ApplyDiff(diff)
+	}
+
+	n.Up = parent
+	n.Diff = diff
+	s.Nodes[n.Index] = n
+	n.CountCaptures()
+	n.ComputeHash()
+	report.Kept++
+
+	// keep the same child preferred if it survives
+	var preferred *TreeNode
+	if n.PreferredChild < len(n.Down) {
+		preferred = n.Down[n.PreferredChild]
+	}
+	children := n.Down
+	n.Down = []*TreeNode{}
+	n.PreferredChild = 0
+	// the children share whatever is past the end of history, but each
+	// is done with it before the next one starts
+	history = append(history, &HistoryEntry{n, board.Key()})
+	for _, child := range children {
+		if s.replay(child, n, board, history, report) {
+			if child == preferred {
+				n.PreferredChild = len(n.Down)
+			}
+			n.Down = append(n.Down, child)
+		}
+	}
+	board.UndoDiff(diff)
+	return true
+}
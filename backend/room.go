@@ -30,6 +30,19 @@ type Room struct {
 	password      string
 	auth          map[string]bool
 	nicks         map[string]string
+	collection    []string
+	skipped       []*SkippedSGF
+	variant       Variant
+	spectators    map[string]bool
+	// whether the server picks the color of each move
+	autoColor bool
+	// connections that don't look at the shared current node
+	cursors map[string]*Cursor
+	// the handicap last asked for in the settings
+	handicap int
+	// how far each connection's explorer reaches around its node
+	// (connections that aren't here see the whole tree)
+	windows map[string]int
 }
 
 func NewRoom() *Room {
@@ -39,7 +52,10 @@ func NewRoom() *Room {
 	msgs := make(map[string]*time.Time)
 	auth := make(map[string]bool)
 	nicks := make(map[string]string)
-	return &Room{conns, state, &now, "", msgs, true, nil, "", auth, nicks}
+	spectators := make(map[string]bool)
+	cursors := make(map[string]*Cursor)
+	windows := make(map[string]int)
+	return &Room{conns, state, &now, "", msgs, true, nil, "", auth, nicks, nil, nil, NormalGo, spectators, false, cursors, 0, windows}
 }
 
 func (r *Room) HasPassword() bool {
@@ -59,6 +75,11 @@ func (r *Room) Broadcast(evt *EventJSON, setTime bool) {
 	// augment event with connection id
 	id := evt.UserID
 
+	frame, isFrame := evt.Value.(*Frame)
+	if isFrame {
+		r.Decorate(frame)
+	}
+
 	// marshal event back into data
 	data, err := json.Marshal(evt)
 	if err != nil {
@@ -66,9 +87,33 @@ func (r *Room) Broadcast(evt *EventJSON, setTime bool) {
 		return
 	}
 
+	// players in a masking variant get their own view of frames
+	masked := data
+	if isFrame && r.variant.Masks() {
+		maskedEvt := *evt
+		maskedEvt.Value = r.variant.Mask(frame)
+		masked, err = json.Marshal(&maskedEvt)
+		if err != nil {
+			log.Println(id, err)
+			return
+		}
+	}
+
 	// rebroadcast message
-	for _, conn := range r.conns {
-		conn.Write(data)
+	for connID, conn := range r.conns {
+		// frames and marks are for the shared current node
+		if (isFrame || NodeEvent(evt.Event)) && r.ViewOf(connID) != "" {
+			continue
+		}
+		if isFrame && r.windows[connID] > 0 {
+			windowed := *evt
+			windowed.Value = r.FrameFor(connID, frame)
+			SendEvent(conn, &windowed)
+		} else if r.spectators[connID] {
+			conn.Write(data)
+		} else {
+			conn.Write(masked)
+		}
 	}
 
 	if setTime {
@@ -79,6 +124,37 @@ func (r *Room) Broadcast(evt *EventJSON, setTime bool) {
 	}
 }
 
+// Decorate adds what the room knows about a frame (the variant's winner)
+func (r *Room) Decorate(frame *Frame) {
+	if r.variant == AtariGo {
+		frame.Winner = r.State.FirstCapture()
+	}
+}
+
+// FrameFor is the frame a particular connection gets to see,
+// cut down to its explorer window and masked for its variant
+// the frame passed in is left alone
+func (r *Room) FrameFor(id string, frame *Frame) *Frame {
+	r.Decorate(frame)
+	if size := r.windows[id]; size > 0 && frame.Explorer != nil {
+		windowed := *frame
+		windowed.Explorer = r.State.ExplorerAt(r.nodeOf(id), size)
+		frame = &windowed
+	}
+	if r.spectators[id] {
+		return frame
+	}
+	return r.variant.Mask(frame)
+}
+
+// nodeOf is the node a connection is looking at
+func (r *Room) nodeOf(id string) *TreeNode {
+	if view := r.ViewOf(id); view != "" {
+		return r.cursors[view].Current
+	}
+	return r.State.Current
+}
+
 func (r *Room) PushHead(x, y, col int) *EventJSON {
 	r.State.PushHead(x, y, col)
 	evt := &EventJSON{
@@ -98,12 +174,93 @@ func (r *Room) UploadSGF(sgf string) *EventJSON {
 		return ErrorJSON(msg)
 	}
 	r.State = state
+	r.handicap = state.Handicap()
 
 	// replace evt with initdata
 	frame := r.State.GenerateFullFrame(true)
 	return FrameJSON(frame)
 }
 
+// UploadGames loads one or more games, merging them as branches
+// when there is more than one
+// the games are kept around so one can be opened on its own later
+func (r *Room) UploadGames(games []string) *EventJSON {
+	merged, skipped := MergeWithReport(games)
+	r.skipped = skipped
+	r.collection = nil
+	bcast := r.UploadSGF(merged)
+	if bcast.Event != "error" && len(games) > 1 {
+		r.collection = games
+	}
+	return bcast
+}
+
+// UploadFiles reads games out of uploaded files and loads them
+// files that can't be read go in the merge report with their names
+// (after any already rejected), as do the games that can't be merged
+func (r *Room) UploadFiles(files []*NamedFile, rejected []*SkippedSGF) *EventJSON {
+	games := []string{}
+	names := []string{}
+	for _, file := range files {
+		g, err := GamesFromBytes(file.Data)
+		if err != nil {
+			rejected = append(rejected, &SkippedSGF{-1, err.Error(), file.Name})
+			continue
+		}
+		for range g {
+			names = append(names, file.Name)
+		}
+		games = append(games, g...)
+	}
+
+	var bcast *EventJSON
+	if len(games) == 0 {
+		r.collection = nil
+		r.skipped = nil
+		bcast = ErrorJSON("none of the uploaded files could be read")
+		if len(rejected) == 1 {
+			bcast = ErrorJSON(rejected[0].Reason)
+		}
+	} else {
+		bcast = r.UploadGames(games)
+		for _, s := range r.skipped {
+			if s.Index >= 0 && s.Index < len(names) {
+				s.File = names[s.Index]
+			}
+		}
+	}
+	r.skipped = append(rejected, r.skipped...)
+	return bcast
+}
+
+// Skipped is the merge report of the last upload
+func (r *Room) Skipped() []*SkippedSGF {
+	return r.skipped
+}
+
+// SendUploadInfo lets the uploader know which games are available
+// to choose from and which ones couldn't be merged
+func (r *Room) SendUploadInfo(id string) {
+	if len(r.collection) > 1 {
+		evt := &EventJSON{
+			"collection",
+			GameInfos(r.collection),
+			0,
+			"",
+		}
+		r.SendTo(id, evt)
+	}
+	if len(r.skipped) > 0 {
+		evt := &EventJSON{
+			"merge_report",
+			r.skipped,
+			0,
+			"",
+		}
+		r.SendTo(id, evt)
+	}
+}
+
 func (r *Room) SendUserList() {
 	// send list of currently connected users
 	evt := &EventJSON{
@@ -0,0 +1,112 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+/*
+reference: https://www.red-bean.com/sgf/properties.html#RU
+the RU property is free text, but the spec lists these names:
+AGA, GOE, Japanese, NZ
+everything else is whatever the recording program felt like writing
+*/
+
+type Ruleset int
+
+const (
+	Japanese Ruleset = iota
+	Chinese
+	Korean
+	AGA
+	Ing
+	NewZealand
+	TrompTaylor
+)
+
+func (r Ruleset) String() string {
+	switch r {
+	case Chinese:
+		return "Chinese"
+	case Korean:
+		return "Korean"
+	case AGA:
+		return "AGA"
+	case Ing:
+		return "GOE"
+	case NewZealand:
+		return "NZ"
+	case TrompTaylor:
+		return "Tromp-Taylor"
+	}
+	return "Japanese"
+}
+
+// ParseRuleset maps the free text of an RU field to a ruleset
+// anything unrecognized falls back to japanese rules
+func ParseRuleset(s string) Ruleset {
+	t := strings.ToLower(strings.TrimSpace(s))
+	t = strings.ReplaceAll(t, "-", " ")
+	t = strings.ReplaceAll(t, "_", " ")
+	switch t {
+	case "chinese", "cn":
+		return Chinese
+	case "korean", "kr":
+		return Korean
+	case "aga", "american", "bga", "french":
+		return AGA
+	case "goe", "ing", "ing goe":
+		return Ing
+	case "nz", "new zealand", "newzealand":
+		return NewZealand
+	case "tromp taylor", "tromptaylor", "tt":
+		return TrompTaylor
+	}
+	return Japanese
+}
+
+// AllowsSuicide reports whether a move may leave its own group
+// without liberties (the group is then removed from the board)
+func (r Ruleset) AllowsSuicide() bool {
+	return r == NewZealand || r == TrompTaylor || r == Ing
+}
+
+type KoRule int
+
+const (
+	// only the immediate recapture of a ko is forbidden
+	SimpleKo KoRule = iota
+	// no move may recreate any earlier board position
+	PositionalSuperko
+	// no move may recreate an earlier position with the same player to move
+	SituationalSuperko
+)
+
+func (k KoRule) String() string {
+	switch k {
+	case PositionalSuperko:
+		return "positional superko"
+	case SituationalSuperko:
+		return "situational superko"
+	}
+	return "simple ko"
+}
+
+func (r Ruleset) Ko() KoRule {
+	switch r {
+	case Chinese, TrompTaylor:
+		return PositionalSuperko
+	case AGA, NewZealand, Ing:
+		return SituationalSuperko
+	}
+	return SimpleKo
+}
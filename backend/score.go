@@ -0,0 +1,285 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/*
+scoring works in two steps:
+1. participants mark groups dead (toggle_dead) on a node in scoring mode
+2. with the dead stones taken off, every empty region that only touches
+   one color is that color's territory, and regions touching both colors
+   (dame, and the shared liberties of a seki) count for nobody
+   under territory scoring the eyes of groups in seki are not territory
+   either
+
+territory scoring (japanese, korean): territory + prisoners + dead stones
+area scoring (everything else): territory + stones on the board
+*/
+
+// AreaScoring reports whether stones on the board count toward the score
+func (r Ruleset) AreaScoring() bool {
+	return r != Japanese && r != Korean
+}
+
+type Scoring struct {
+	Node *TreeNode
+	Dead CoordSet
+}
+
+type Score struct {
+	Black     float64 `json:"black"`
+	White     float64 `json:"white"`
+	Komi      float64 `json:"komi"`
+	Area      bool    `json:"area"`
+	Result    string  `json:"result"`
+	Confirmed bool    `json:"confirmed"`
+
+	BlackTerritory []*Coord `json:"-"`
+	WhiteTerritory []*Coord `json:"-"`
+	Dame           []*Coord `json:"-"`
+}
+
+// inSeki finds the stones of groups that share a liberty with an
+// opponent group where filling it would be self-atari for either side
+func inSeki(b *Board) CoordSet {
+	seki := NewCoordSet()
+	for _, gp := range b.Groups() {
+		if seki.Has(gp.Coords.List()[0]) {
+			continue
+		}
+		for _, lib := range gp.Libs {
+			for _, nb := range b.Neighbors(lib) {
+				if b.Get(nb) != Opposite(gp.Color) {
+					continue
+				}
+				if selfAtari(b, lib, gp.Color) && selfAtari(b, lib, Opposite(gp.Color)) {
+					for _, c := range gp.Coords {
+						seki.Add(c)
+					}
+					for _, c := range b.FindGroup(nb).Coords {
+						seki.Add(c)
+					}
+				}
+			}
+		}
+	}
+	return seki
+}
+
+// selfAtari reports whether playing col at c (capturing whatever it
+// captures) leaves it with one liberty, or can't be played at all
+func selfAtari(b *Board, c *Coord, col Color) bool {
+	board := b.Copy()
+	if board.Move(c, col) == nil || board.Get(c) != col {
+		return true
+	}
+	return len(board.FindGroup(c).Libs) <= 1
+}
+
+// Territory flood fills the empty regions of a board and sorts them
+// by which colors they touch
+// under territory scoring, the eyes of groups in seki (regions that
+// only border stones in seki) are dame
+func Territory(b *Board) (CoordSet, CoordSet, CoordSet) {
+	black := NewCoordSet()
+	white := NewCoordSet()
+	dame := NewCoordSet()
+	seen := NewCoordSet()
+
+	seki := NewCoordSet()
+	if !b.Rules.AreaScoring() {
+		seki = inSeki(b)
+	}
+
+	for j := 0; j < b.Size; j++ {
+		for i := 0; i < b.Size; i++ {
+			start := &Coord{i, j}
+			if b.Get(start) != NoColor || seen.Has(start) {
+				continue
+			}
+
+			region := NewCoordSet()
+			touchesBlack := false
+			touchesWhite := false
+			// whether any stone around the region isn't in seki
+			touchesAlive := false
+			stack := []*Coord{start}
+			seen.Add(start)
+			for len(stack) > 0 {
+				point := stack[len(stack)-1]
+				stack = stack[:len(stack)-1]
+				region.Add(point)
+				for _, nb := range b.Neighbors(point) {
+					if b.Get(nb) != NoColor && !seki.Has(nb) {
+						touchesAlive = true
+					}
+					switch b.Get(nb) {
+					case Black:
+						touchesBlack = true
+					case White:
+						touchesWhite = true
+					default:
+						if !seen.Has(nb) {
+							seen.Add(nb)
+							stack = append(stack, nb)
+						}
+					}
+				}
+			}
+
+			// eyes of a group in seki count for nobody
+			owner := dame
+			if len(seki) > 0 && !touchesAlive {
+				owner = dame
+			} else if touchesBlack && !touchesWhite {
+				owner = black
+			} else if touchesWhite && !touchesBlack {
+				owner = white
+			}
+			for _, c := range region {
+				owner.Add(c)
+			}
+		}
+	}
+	return black, white, dame
+}
+
+// CountScore scores a board with the given stones marked dead
+// blackPrisoners is the number of white stones black captured during
+// the game (and vice versa), they only matter for territory scoring
+func CountScore(b *Board, dead CoordSet, komi float64, blackPrisoners, whitePrisoners int) *Score {
+	board := b.Copy()
+	for _, c := range dead {
+		switch board.Get(c) {
+		case Black:
+			whitePrisoners++
+		case White:
+			blackPrisoners++
+		}
+		board.Set(c, NoColor)
+	}
+
+	blackTerritory, whiteTerritory, dame := Territory(board)
+
+	score := &Score{
+		Komi:           komi,
+		Area:           b.Rules.AreaScoring(),
+		BlackTerritory: blackTerritory.List(),
+		WhiteTerritory: whiteTerritory.List(),
+		Dame:           dame.List(),
+	}
+
+	score.Black = float64(len(blackTerritory))
+	score.White = float64(len(whiteTerritory)) + komi
+	if score.Area {
+		for _, row := range board.Points {
+			for _, c := range row {
+				if c == Black {
+					score.Black++
+				} else if c == White {
+					score.White++
+				}
+			}
+		}
+	} else {
+		score.Black += float64(blackPrisoners)
+		score.White += float64(whitePrisoners)
+	}
+
+	score.Result = ResultString(score.Black - score.White)
+	return score
+}
+
+// ResultString formats a margin (from black's point of view) for RE
+func ResultString(margin float64) string {
+	if margin > 0 {
+		return "B+" + strconv.FormatFloat(margin, 'f', -1, 64)
+	} else if margin < 0 {
+		return "W+" + strconv.FormatFloat(-margin, 'f', -1, 64)
+	}
+	return "0"
+}
+
+// Komi reads KM from the root, defaulting to 0
+func (s *State) Komi() float64 {
+	if km, ok := s.Root.Fields["KM"]; ok && len(km) > 0 {
+		komi, err := strconv.ParseFloat(km[0], 64)
+		if err == nil {
+			return komi
+		}
+	}
+	return 0
+}
+
+func (s *State) InScoring() bool {
+	return s.Scoring != nil && s.Scoring.Node == s.Current
+}
+
+func (s *State) StartScoring() {
+	s.Scoring = &Scoring{s.Current, NewCoordSet()}
+}
+
+func (s *State) ToggleDead(c *Coord) error {
+	if !s.InScoring() {
+		return fmt.Errorf("not in scoring mode")
+	}
+	gp := s.Board.FindGroup(c)
+	if gp.Color == NoColor {
+		return nil
+	}
+
+	// if the whole group is already dead, bring it back to life
+	if gp.Coords.IsSubsetOf(s.Scoring.Dead) {
+		for key := range gp.Coords {
+			delete(s.Scoring.Dead, key)
+		}
+		return nil
+	}
+	for _, coord := range gp.Coords {
+		s.Scoring.Dead.Add(coord)
+	}
+	return nil
+}
+
+func (s *State) CurrentScore() *Score {
+	if !s.InScoring() {
+		return nil
+	}
+	captures := s.Captures()
+	return CountScore(s.Board, s.Scoring.Dead, s.Komi(), captures.Black, captures.White)
+}
+
+// ConfirmScore writes TB, TW and RE into the tree and leaves scoring mode
+func (s *State) ConfirmScore() (*Score, error) {
+	score := s.CurrentScore()
+	if score == nil {
+		return nil, fmt.Errorf("not in scoring mode")
+	}
+	score.Confirmed = true
+
+	// territory includes the points where dead stones were
+	delete(s.Current.Fields, "TB")
+	delete(s.Current.Fields, "TW")
+	for _, c := range score.BlackTerritory {
+		s.Current.AddField("TB", c.ToLetters())
+	}
+	for _, c := range score.WhiteTerritory {
+		s.Current.AddField("TW", c.ToLetters())
+	}
+	s.Root.Fields["RE"] = []string{score.Result}
+
+	s.Scoring = nil
+	return score, nil
+}
@@ -0,0 +1,166 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	"fmt"
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+// black owns the left side (columns a-d), white the right (g-i)
+// with a dead white stone at bb
+const scoreSGF = "(;GM[1]SZ[9]KM[6.5]RU[%s]" +
+	"AB[ea][eb][ec][ed][ee][ef][eg][eh][ei]" +
+	"AW[fa][fb][fc][fd][fe][ff][fg][fh][fi][bb])"
+
+func scoreState(t *testing.T, rules string) *backend.State {
+	s, err := backend.FromSGF(fmt.Sprintf(scoreSGF, rules))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return s
+}
+
+func TestScoreJapanese(t *testing.T) {
+	s := scoreState(t, "Japanese")
+	s.StartScoring()
+	if err := s.ToggleDead(&backend.Coord{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	score, err := s.ConfirmScore()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 36 points of territory + 1 dead stone vs 27 + 6.5
+	if score.Black != 37 || score.White != 33.5 {
+		t.Errorf("expected 37 to 33.5, got: %v to %v", score.Black, score.White)
+	}
+	if s.Root.Fields["RE"][0] != "B+3.5" {
+		t.Errorf("expected B+3.5, got: %v", s.Root.Fields["RE"])
+	}
+	if len(s.Current.Fields["TB"]) != 36 || len(s.Current.Fields["TW"]) != 27 {
+		t.Errorf("wrong territory: %d, %d", len(s.Current.Fields["TB"]), len(s.Current.Fields["TW"]))
+	}
+	if s.InScoring() {
+		t.Errorf("confirming should leave scoring mode")
+	}
+}
+
+func TestScoreChinese(t *testing.T) {
+	s := scoreState(t, "Chinese")
+	s.StartScoring()
+	s.ToggleDead(&backend.Coord{1, 1})
+	score := s.CurrentScore()
+
+	// 36 + 9 stones vs 27 + 9 stones + 6.5
+	if score.Result != "B+2.5" {
+		t.Errorf("expected B+2.5, got: %s", score.Result)
+	}
+
+	// toggling again brings the stone back to life
+	// and the left side is no longer anybody's
+	s.ToggleDead(&backend.Coord{1, 1})
+	score = s.CurrentScore()
+	if len(score.BlackTerritory) != 0 {
+		t.Errorf("expected no black territory, got: %d", len(score.BlackTerritory))
+	}
+}
+
+func TestScoreSeki(t *testing.T) {
+	// the shared liberty at ia counts for nobody
+	b := backend.NewBoard(9)
+	b.Set(&backend.Coord{7, 0}, backend.Black)
+	b.Set(&backend.Coord{8, 1}, backend.White)
+	_, _, dame := backend.Territory(b)
+	if !dame.Has(&backend.Coord{8, 0}) {
+		t.Errorf("expected shared liberty to be dame")
+	}
+}
+
+func TestScoreSekiEyes(t *testing.T) {
+	// each group has one eye (aa for black, ea for white)
+	// and they share the liberty at ca
+	rows := []string{
+		".B.W.",
+		"BBBWW",
+		"BBWWW",
+		"BBBWW",
+		"BBWWW",
+	}
+	b := backend.NewBoard(5)
+	for j, row := range rows {
+		for i, p := range row {
+			switch p {
+			case 'B':
+				b.Set(&backend.Coord{i, j}, backend.Black)
+			case 'W':
+				b.Set(&backend.Coord{i, j}, backend.White)
+			}
+		}
+	}
+
+	black, white, dame := backend.Territory(b)
+	if len(black) != 0 || len(white) != 0 {
+		t.Errorf("expected no territory in seki, got: %v, %v", black, white)
+	}
+	for _, c := range []*backend.Coord{{0, 0}, {2, 0}, {4, 0}} {
+		if !dame.Has(c) {
+			t.Errorf("expected %v to be dame", c)
+		}
+	}
+
+	// under area scoring the eyes are still points
+	b.Rules = backend.Chinese
+	black, white, _ = backend.Territory(b)
+	if !black.Has(&backend.Coord{0, 0}) || !white.Has(&backend.Coord{4, 0}) {
+		t.Errorf("expected eyes to count under area scoring, got: %v, %v", black, white)
+	}
+}
+
+func TestScoreSekiTerritory(t *testing.T) {
+	// the black group at the top left is in seki, with no eye of its
+	// own, but the rest of black's area (which it touches at af)
+	// is walled off by living stones
+	rows := []string{
+		"BB.W.B...",
+		"BBBWWB...",
+		"BBWWWB...",
+		"BBBWWB...",
+		"BWWWWB...",
+		".BBBBB...",
+		".........",
+		".........",
+		".........",
+	}
+	b := backend.NewBoard(9)
+	for j, row := range rows {
+		for i, p := range row {
+			switch p {
+			case 'B':
+				b.Set(&backend.Coord{i, j}, backend.Black)
+			case 'W':
+				b.Set(&backend.Coord{i, j}, backend.White)
+			}
+		}
+	}
+
+	black, _, dame := backend.Territory(b)
+	for _, c := range []*backend.Coord{{0, 5}, {8, 8}, {8, 0}} {
+		if !black.Has(c) {
+			t.Errorf("expected %v to be black territory", c)
+		}
+	}
+	if !dame.Has(&backend.Coord{2, 0}) {
+		t.Errorf("expected the shared liberty to be dame")
+	}
+}
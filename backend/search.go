@@ -0,0 +1,176 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// the most results a search sends back
+const MaxSearchResults = 500
+
+// how much of a comment to show either side of a match
+const searchContext = 30
+
+// the SGF properties that count as marks
+var markKeys = []string{"TR", "SQ", "CR", "MA", "LB", "PX"}
+
+// SearchQuery is what to look for, every part given has to match
+type SearchQuery struct {
+	Text  string `json:"text"`
+	Regex bool   `json:"regex"`
+	Coord *Coord `json:"coord"`
+	Color Color  `json:"color"`
+	Marks bool   `json:"marks"`
+}
+
+type SearchResult struct {
+	Index int    `json:"index"`
+	Depth int    `json:"depth"`
+	Coord *Coord `json:"coord"`
+	Color Color  `json:"color"`
+	// part of the comment around the match, or the node's name
+	Context string `json:"context"`
+}
+
+type SearchResults struct {
+	Results []*SearchResult `json:"results"`
+	// there were more than MaxSearchResults
+	Truncated bool `json:"truncated"`
+}
+
+// ParseSearch reads a query from an event value
+func ParseSearch(value interface{}) (*SearchQuery, error) {
+	m, ok := value.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("invalid search")
+	}
+	q := &SearchQuery{}
+	q.Text, _ = m["text"].(string)
+	q.Regex, _ = m["regex"].(bool)
+	q.Marks, _ = m["marks"].(bool)
+	if col, ok := m["color"].(float64); ok {
+		q.Color = Color(col)
+	}
+	if c, ok := m["coord"]; ok && c != nil {
+		coord, err := InterfaceToCoord(c)
+		if err != nil {
+			return nil, err
+		}
+		q.Coord = coord
+	}
+	if q.Text == "" && q.Coord == nil && q.Color == NoColor && !q.Marks {
+		return nil, fmt.Errorf("nothing to search for")
+	}
+	return q, nil
+}
+
+// a matcher finds the first match of the text part of a query in s,
+// returning its start and end, or -1, -1
+type matcher func(s string) (int, int)
+
+func (q *SearchQuery) matcher() (matcher, error) {
+	// plain text is case insensitive
+	// (lowering the text could change its length, and the match
+	// has to index into the original for the snippet)
+	expr := "(?i)" + regexp.QuoteMeta(q.Text)
+	if q.Regex {
+		expr = q.Text
+	}
+	re, err := regexp.Compile(expr)
+	if err != nil {
+		return nil, fmt.Errorf("invalid regex: %v", err)
+	}
+	return func(s string) (int, int) {
+		loc := re.FindStringIndex(s)
+		if loc == nil {
+			return -1, -1
+		}
+		return loc[0], loc[1]
+	}, nil
+}
+
+func hasMarks(n *TreeNode) bool {
+	for _, key := range markKeys {
+		if len(n.Fields[key]) > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// snippet cuts the text around a match down to size
+func snippet(s string, start, end int) string {
+	from := start - searchContext
+	to := end + searchContext
+	prefix, suffix := "...", "..."
+	if from <= 0 {
+		from, prefix = 0, ""
+	}
+	if to >= len(s) {
+		to, suffix = len(s), ""
+	}
+	// don't cut a character in half
+	for from > 0 && !utf8.RuneStart(s[from]) {
+		from--
+	}
+	for to < len(s) && !utf8.RuneStart(s[to]) {
+		to++
+	}
+	return prefix + strings.TrimSpace(s[from:to]) + suffix
+}
+
+// Search looks through the whole tree, in tree order
+func (s *State) Search(q *SearchQuery) (*SearchResults, error) {
+	match, err := q.matcher()
+	if err != nil {
+		return nil, err
+	}
+
+	results := &SearchResults{[]*SearchResult{}, false}
+	depth := map[*TreeNode]int{}
+	Fmap(func(n *TreeNode) {
+		if n.Up != nil {
+			depth[n] = depth[n.Up] + 1
+		}
+		if results.Truncated {
+			return
+		}
+		if q.Coord != nil && (n.XY == nil || n.XY.X != q.Coord.X || n.XY.Y != q.Coord.Y) {
+			return
+		}
+		if q.Color != NoColor && n.Color != q.Color {
+			return
+		}
+		if q.Marks && !hasMarks(n) {
+			return
+		}
+		context := n.NodeName()
+		if q.Text != "" {
+			comment := strings.Join(n.Fields["C"], "")
+			start, end := match(comment)
+			if start == -1 {
+				return
+			}
+			context = snippet(comment, start, end)
+		}
+		if len(results.Results) == MaxSearchResults {
+			results.Truncated = true
+			return
+		}
+		result := &SearchResult{n.Index, depth[n], n.XY, n.Color, context}
+		results.Results = append(results.Results, result)
+	}, s.Root)
+	return results, nil
+}
@@ -94,43 +94,55 @@ func (s *Server) Load() {
 			continue
 		}
 
-		load := &LoadJSON{}
-		err = json.Unmarshal(data, load)
+		r, err := LoadRoom(data)
 		if err != nil {
 			continue
 		}
 
-		sgf, err := base64.StdEncoding.DecodeString(load.SGF)
-		if err != nil {
-			continue
-		}
+		log.Printf("Loading %s", path)
 
-		state, err := FromSGF(string(sgf))
-		if err != nil {
-			continue
-		}
+		s.rooms[id] = r
+		go s.Heartbeat(id)
+	}
+}
 
-		state.SetPrefs(load.Prefs)
+// LoadRoom makes a room out of what Save wrote for it
+func LoadRoom(data []byte) (*Room, error) {
+	load := &LoadJSON{}
+	err := json.Unmarshal(data, load)
+	if err != nil {
+		return nil, err
+	}
 
-		state.NextIndex = load.NextIndex
-		state.InputBuffer = load.Buffer
+	sgf, err := base64.StdEncoding.DecodeString(load.SGF)
+	if err != nil {
+		return nil, err
+	}
 
-		loc := load.Loc
-		if loc != "" {
-			dirs := strings.Split(loc, ",")
-			for _ = range dirs {
-				state.Right()
-			}
-		}
+	state, err := FromSGF(string(sgf))
+	if err != nil {
+		return nil, err
+	}
 
-		log.Printf("Loading %s", path)
+	state.SetPrefs(load.Prefs)
+	state.SetCollapsedNodes(load.Collapsed)
 
-		r := NewRoom()
-		r.password = load.Password
-		r.State = state
-		s.rooms[id] = r
-		go s.Heartbeat(id)
+	state.NextIndex = load.NextIndex
+	state.InputBuffer = load.Buffer
+
+	loc := load.Loc
+	if loc != "" {
+		dirs := strings.Split(loc, ",")
+		for _ = range dirs {
+			state.Right()
+		}
 	}
+
+	r := NewRoom()
+	r.password = load.Password
+	r.State = state
+	r.handicap = state.Handicap()
+	return r, nil
 }
 
 func (s *Server) Heartbeat(roomID string) {
@@ -315,7 +327,7 @@ func (room *Room) NewConnection(ws *websocket.Conn, first bool) string {
 	// send initial state if it's not the first connection
 	if !first {
 		frame := room.State.GenerateFullFrame(true)
-		evt := FrameJSON(frame)
+		evt := FrameJSON(room.FrameFor(id, frame))
 		SendEvent(ws, evt)
 	}
 
@@ -380,6 +392,9 @@ func (s *Server) Handler(ws *websocket.Conn) {
 	// golang deferrals are called in LIFO order
 	defer room.SendUserList()
 	defer delete(room.nicks, id)
+	defer delete(room.spectators, id)
+	defer delete(room.cursors, id)
+	defer delete(room.windows, id)
 
 	handlers := map[string]EventHandler{
 		"isprotected":   room.HandleIsProtected,
@@ -392,19 +407,29 @@ func (s *Server) Handler(ws *websocket.Conn) {
 			room.OutsideBuffer,
 			room.Authorized,
 			room.CloseOGS,
-			room.BroadcastAfter(false)),
+			room.BroadcastAfter(false),
+			room.RefreshViewsAfter),
 		"request_sgf": Chain(
 			room.HandleRequestSGF,
 			room.OutsideBuffer,
 			room.Authorized,
 			room.CloseOGS,
-			room.BroadcastAfter(false)),
+			room.BroadcastAfter(false),
+			room.RefreshViewsAfter),
+		"choose_game": Chain(
+			room.HandleChooseGame,
+			room.OutsideBuffer,
+			room.Authorized,
+			room.CloseOGS,
+			room.BroadcastAfter(false),
+			room.RefreshViewsAfter),
 		"trash": Chain(
 			room.HandleTrash,
 			room.OutsideBuffer,
 			room.Authorized,
 			room.CloseOGS,
-			room.BroadcastAfter(false)),
+			room.BroadcastAfter(false),
+			room.RefreshViewsAfter),
 		"update_nickname": Chain(
 			room.HandleUpdateNickname,
 			room.BroadcastAfter(false)),
@@ -413,13 +438,35 @@ func (s *Server) Handler(ws *websocket.Conn) {
 			room.Authorized,
 			room.BroadcastConnectedUsersAfter,
 			room.BroadcastAfter(false),
-			room.BroadcastFullFrameAfter),
+			room.BroadcastFullFrameAfter,
+			room.RefreshViewsAfter),
+		"spectate": Chain(
+			room.HandleSpectate,
+			room.Authorized),
+		"search": Chain(
+			room.HandleSearch,
+			room.Authorized),
+		"private_cursor": Chain(
+			room.HandleCursor,
+			room.Authorized),
+		"follow": Chain(
+			room.HandleCursor,
+			room.Authorized),
+		"explorer_window": Chain(
+			room.HandleExplorerWindow,
+			room.Authorized),
 		"add_stone": Chain(
 			room.HandleEvent,
 			room.OutsideBuffer,
 			room.Authorized,
 			room.Slow,
 			room.BroadcastAfter(true)),
+		"place_stone": Chain(
+			room.HandleEvent,
+			room.OutsideBuffer,
+			room.Authorized,
+			room.Slow,
+			room.BroadcastAfter(true)),
 		"_": Chain(
 			room.HandleEvent,
 			room.OutsideBuffer,
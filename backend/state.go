@@ -12,9 +12,11 @@ package main
 
 import (
 	"encoding/base64"
+	"encoding/json"
 	"fmt"
 	"strconv"
 	"strings"
+	"time"
 )
 
 const Letters = "ABCDEFGHIJKLNMOPQRSTUVWXYZ"
@@ -23,6 +25,10 @@ type Settings struct {
 	Buffer   int64
 	Size     int
 	Password string
+	Rules    string
+	// -1 means the handicap wasn't given
+	Handicap     int
+	FreeHandicap bool
 }
 
 type Coord struct {
@@ -86,7 +92,18 @@ type State struct {
 	Timeout     float64
 	Size        int
 	Board       *Board
-	Clipboard	*TreeNode
+	Clipboard   *TreeNode
+	Scoring     *Scoring
+	// handicap stones black still has to place (free placement)
+	FreeHandicap int
+	// whether marks include the tactics overlay
+	Tactics bool
+	// the explorer layout, see ExplorerAt
+	grid *Grid
+	// who new nodes are credited to during an event, see addEvent
+	maker *Credit
+	// see CurrentTactics
+	tactics map[*TreeNode]*cachedTactics
 }
 
 func (s *State) Prefs() string {
@@ -217,6 +234,17 @@ func (s *State) Locate() string {
 	return result
 }
 
+// SetRules changes the ruleset used for legality and records it
+// in the root RU field so it survives save and load
+func (s *State) SetRules(r Ruleset) {
+	// legality (and so what tactics find) depends on the rules
+	s.tactics = nil
+	s.Board.Rules = r
+	if s.Root != nil {
+		s.Root.Fields["RU"] = []string{r.String()}
+	}
+}
+
 func (s *State) GetNextIndex() int {
 	i := s.NextIndex
 	s.NextIndex++
@@ -224,12 +252,13 @@ func (s *State) GetNextIndex() int {
 }
 
 func (s *State) AddFieldNode(fields map[string][]string, index int) *Diff {
+	s.changed()
 	tmp := s.GetNextIndex()
 	if index == -1 {
 		index = tmp
 	}
 	n := NewTreeNode(nil, -1, index, s.Current, fields)
-	s.Nodes[index] = n
+	s.track(n)
 	if s.Root == nil {
 		s.Root = n
 	} else {
@@ -238,7 +267,16 @@ func (s *State) AddFieldNode(fields map[string][]string, index int) *Diff {
 	}
 	s.Current = n
 
-	// compute diff
+	diff := SetupDiff(s.Board, fields)
+	s.Board.ApplyDiff(diff)
+	s.Current.Diff = diff
+	s.Current.CountCaptures()
+	s.Current.ComputeHash()
+	return diff
+}
+
+// SetupDiff computes the diff of a setup node (AB, AW, AE) on a board
+func SetupDiff(b *Board, fields map[string][]string) *Diff {
 	diffAdd := []*StoneSet{}
 	if val, ok := fields["AB"]; ok {
 		add := NewCoordSet()
@@ -264,7 +302,7 @@ func (s *State) AddFieldNode(fields map[string][]string, index int) *Diff {
 		csWhite := NewCoordSet()
 		for _, v := range val {
 			coord := LettersToCoord(v)
-			col := s.Board.Get(coord)
+			col := b.Get(coord)
 			if col == Black {
 				csBlack.Add(coord)
 			} else if col == White {
@@ -277,19 +315,17 @@ func (s *State) AddFieldNode(fields map[string][]string, index int) *Diff {
 		diffRemove = append(diffRemove, removeWhite)
 	}
 
-	diff := NewDiff(diffAdd, diffRemove)
-	s.Board.ApplyDiff(diff)
-	s.Current.Diff = diff
-	return diff
+	return NewDiff(diffAdd, diffRemove)
 }
 
 func (s *State) AddPassNode(col Color, fields map[string][]string, index int) {
+	s.changed()
 	tmp := s.GetNextIndex()
 	if index == -1 {
 		index = tmp
 	}
 	n := NewTreeNode(nil, col, index, s.Current, fields)
-	s.Nodes[index] = n
+	s.track(n)
 	if s.Root == nil {
 		s.Root = n
 	} else {
@@ -298,9 +334,12 @@ func (s *State) AddPassNode(col Color, fields map[string][]string, index int) {
 	}
 	s.Current = n
 	// no need to add a diff
+	s.Current.CountCaptures()
+	s.Current.ComputeHash()
 }
 
 func (s *State) PushHead(x, y, col int) {
+	s.changed()
 	coord := &Coord{x, y}
 	if x == -1 || y == -1 {
 		coord = nil
@@ -319,7 +358,7 @@ func (s *State) PushHead(x, y, col int) {
 	}
 	fields[key] = []string{value}
 	n := NewTreeNode(coord, Color(col), index, s.Head, fields)
-	s.Nodes[index] = n
+	s.track(n)
 	if len(s.Head.Down) > 0 {
 		s.Head.PreferredChild++
 	}
@@ -364,9 +403,12 @@ func (s *State) PushHead(x, y, col int) {
 
 	// set diff
 	s.Head.Diff = diff
+	s.Head.CountCaptures()
+	s.Head.ComputeHash()
 }
 
 func (s *State) AddNode(coord *Coord, col Color, fields map[string][]string, index int, force bool) *Diff {
+	s.changed()
 	if fields == nil {
 		fields = make(map[string][]string)
 	}
@@ -393,7 +435,7 @@ func (s *State) AddNode(coord *Coord, col Color, fields map[string][]string, ind
 	}
 	n := NewTreeNode(coord, Color(col), index, s.Current, fields)
 
-	s.Nodes[index] = n
+	s.track(n)
 	if s.Root == nil {
 		s.Root = n
 	} else {
@@ -403,9 +445,16 @@ func (s *State) AddNode(coord *Coord, col Color, fields map[string][]string, ind
 	s.Current = n
 	diff := s.Board.Move(coord, Color(col))
 	s.Current.Diff = diff
+	s.Current.CountCaptures()
+	s.Current.ComputeHash()
 	return diff
 }
 
+// Captures are the stones captured on the way to the current node
+func (s *State) Captures() Captures {
+	return s.Current.Captures
+}
+
 type PatternMove struct {
     Coord *Coord  // nil for passes
     Color Color
@@ -453,6 +502,7 @@ func (s *State) AddPatternNodes(moves []*PatternMove) {
 }
 
 func (s *State) Cut() *Diff {
+	s.changed()
 	// store the current index
 	index := s.Current.Index
 
@@ -496,6 +546,66 @@ func (s *State) Cut() *Diff {
 	return diff
 }
 
+// fork finds the nearest branching point above a node, returning the
+// node at the fork and the child of it that leads to n
+func fork(n *TreeNode) (*TreeNode, *TreeNode) {
+	for child := n; child.Up != nil; child = child.Up {
+		if len(child.Up.Down) > 1 {
+			return child.Up, child
+		}
+	}
+	return nil, nil
+}
+
+// MoveBranch moves the branch containing the node at index one place
+// up (delta -1) or down (delta 1) at the nearest fork
+func (s *State) MoveBranch(index, delta int) error {
+	s.changed()
+	n, ok := s.Nodes[index]
+	if !ok {
+		return fmt.Errorf("no node %d", index)
+	}
+	parent, child := fork(n)
+	if parent == nil {
+		return nil
+	}
+	i := parent.ChildIndex(child)
+	parent.MoveChild(i, i+delta)
+	return nil
+}
+
+// PromoteVariation makes the branch containing the node at index
+// the first one at the nearest fork
+func (s *State) PromoteVariation(index int) error {
+	s.changed()
+	n, ok := s.Nodes[index]
+	if !ok {
+		return fmt.Errorf("no node %d", index)
+	}
+	parent, child := fork(n)
+	if parent == nil {
+		return nil
+	}
+	parent.MoveChild(parent.ChildIndex(child), 0)
+	return nil
+}
+
+// MakeMainline makes the path to the node at index
+// the first branch at every fork
+func (s *State) MakeMainline(index int) error {
+	s.changed()
+	n, ok := s.Nodes[index]
+	if !ok {
+		return fmt.Errorf("no node %d", index)
+	}
+	for child := n; child.Up != nil; child = child.Up {
+		parent := child.Up
+		parent.MoveChild(parent.ChildIndex(child), 0)
+		parent.PreferredChild = 0
+	}
+	return nil
+}
+
 func (s *State) Left() *Diff {
 	if s.Current.Up != nil {
 		d := s.Current.Diff.Invert()
@@ -522,14 +632,46 @@ func (s *State) GotoIndex(index int) error {
 	if err != nil {
 		return err
 	}
-	s.Rewind()
-	for {
-		if s.Current.Index == index {
-			break
+	target := s.Nodes[index]
+
+	// find the common ancestor, remembering the way down to the target
+	a, b := s.Current, target
+	da, db := a.Depth(), b.Depth()
+	down := make([]*TreeNode, 0, db)
+	depth := da
+	for ; da > db; da-- {
+		a = a.Up
+	}
+	for ; db > da; db-- {
+		down = append(down, b)
+		b = b.Up
+	}
+	for a != b {
+		a = a.Up
+		down = append(down, b)
+		b = b.Up
+		da--
+	}
+
+	if depth-da > da {
+		// closer to replay the ancestor from the root
+		s.Rewind()
+		for b = a; b.Up != nil; b = b.Up {
+			down = append(down, b)
+		}
+	} else {
+		// undo diffs on the way up from the current node
+		for cur := s.Current; cur != a; cur = cur.Up {
+			s.Board.UndoDiff(cur.Diff)
 		}
-		s.Right()
 	}
-	//s.Current = s.Nodes[index]
+
+	// then apply diffs on the way down to the target
+	s.Current = a
+	for i := len(down) - 1; i >= 0; i-- {
+		s.Current = down[i]
+		s.Board.ApplyDiff(s.Current.Diff)
+	}
 	return nil
 }
 
@@ -653,13 +795,48 @@ func (s *State) GenerateMarks() *Marks {
 		}
 		marks.Pens = pens
 	}
+
+	// territory comes from the scoring session if there is one
+	// otherwise from TB and TW (e.g. a scored game that was uploaded)
+	if score := s.CurrentScore(); score != nil {
+		marks.BlackTerritory = score.BlackTerritory
+		marks.WhiteTerritory = score.WhiteTerritory
+		marks.Dead = s.Scoring.Dead.List()
+	} else {
+		for _, key := range []string{"TB", "TW"} {
+			cs := NewCoordSet()
+			for _, v := range s.Current.Fields[key] {
+				if c := LettersToCoord(v); c != nil {
+					cs.Add(c)
+				}
+			}
+			if key == "TB" {
+				marks.BlackTerritory = cs.List()
+			} else {
+				marks.WhiteTerritory = cs.List()
+			}
+		}
+	}
+
+	if s.Tactics {
+		t := s.CurrentTactics()
+		marks.Atari = t.Atari
+		marks.Ladders = t.Ladders
+		marks.LadderBreakers = t.LadderBreakers
+		marks.Nets = t.Nets
+		marks.Snapbacks = t.Snapbacks
+	}
+
+	marks.Annotations = s.Current.Annotations()
 	return marks
 }
 
 func (s *State) GenerateMetadata() *Metadata {
+	captures := s.Captures()
 	m := &Metadata{
-		Size:   s.Size,
-		Fields: s.Root.Fields,
+		Size:     s.Size,
+		Fields:   s.Root.Fields,
+		Captures: &captures,
 	}
 	return m
 }
@@ -675,7 +852,7 @@ func (s *State) GenerateComments() []string {
 func (s *State) GenerateFullFrame(init bool) *Frame {
 	frame := s.Board.CurrentFrame()
 	frame.Marks = s.GenerateMarks()
-	frame.Explorer = s.Root.FillGrid(s.Current.Index)
+	frame.Explorer = s.Explorer()
 	if !init {
 		frame.Explorer.Nodes = nil
 		frame.Explorer.Edges = nil
@@ -683,13 +860,50 @@ func (s *State) GenerateFullFrame(init bool) *Frame {
 
 	frame.Metadata = s.GenerateMetadata()
 	frame.Comments = s.GenerateComments()
+	frame.Score = s.CurrentScore()
+	frame.Captures = frame.Metadata.Captures
+	frame.Transpositions = s.Transpositions()
+	frame.Bookmarks = s.Bookmarks()
+	frame.Credits = s.Current.Credits()
+	frame.Turn = s.NextColor()
 	return frame
 
 }
 
 func (s *State) AddEvent(evt *EventJSON) (*Frame, error) {
+	return s.addEvent(evt, "", nil)
+}
+
+// AddEventBy is AddEvent, crediting whatever the event adds to author
+func (s *State) AddEventBy(evt *EventJSON, author string, at time.Time) (*Frame, error) {
+	return s.addEvent(evt, author, &at)
+}
+
+func (s *State) addEvent(evt *EventJSON, author string, at *time.Time) (*Frame, error) {
+	if at != nil {
+		// nodes get credited as they're made, so the explorer has them
+		s.maker = &Credit{"node", "", author, at.Unix()}
+		defer func() { s.maker = nil }()
+	}
+	had := len(s.Current.Fields[creditKinds[evt.Event]])
+	frame, err := s.applyEvent(evt)
+	if err == nil && at != nil {
+		s.credit(evt, author, *at, had)
+	}
+	if frame != nil {
+		captures := s.Captures()
+		frame.Captures = &captures
+		frame.Transpositions = s.Transpositions()
+		frame.Bookmarks = s.Bookmarks()
+		frame.Credits = s.Current.Credits()
+		frame.Turn = s.NextColor()
+	}
+	return frame, err
+}
+
+func (s *State) applyEvent(evt *EventJSON) (*Frame, error) {
 	switch evt.Event {
-	case "add_stone":
+	case "add_stone", "place_stone":
 		return s.HandleAddStone(evt)
 	case "pass":
 		return s.HandlePass(evt)
@@ -707,6 +921,8 @@ func (s *State) AddEvent(evt *EventJSON) (*Frame, error) {
 		return s.HandleRemoveMark(evt)
 	case "cut":
 		return s.HandleCut(evt)
+	case "promote_variation", "move_branch_up", "move_branch_down", "make_mainline":
+		return s.HandleReorder(evt)
 	case "left":
 		return s.HandleLeft()
 	case "right":
@@ -733,38 +949,65 @@ func (s *State) AddEvent(evt *EventJSON) (*Frame, error) {
 		return s.HandleCopy()
 	case "clipboard":
 		return s.HandleClipboard()
+	case "start_scoring":
+		return s.HandleStartScoring()
+	case "toggle_dead":
+		return s.HandleToggleDead(evt)
+	case "confirm_score":
+		return s.HandleConfirmScore()
+	case "cancel_scoring":
+		return s.HandleCancelScoring()
+	case "estimate_score":
+		return s.HandleEstimateScore()
+	case "toggle_tactics":
+		return s.HandleToggleTactics()
+	case "goto_transposition":
+		return s.HandleGotoTransposition(evt)
+	case "delete_node", "insert_move", "insert_pass", "replace_move":
+		return s.HandleEdit(evt)
+	case "collapse", "expand", "expand_all":
+		return s.HandleCollapse(evt)
+	case "set_node_name", "clear_node_name":
+		return s.HandleNodeName(evt)
+	case "compare":
+		return s.HandleCompare(evt)
+	case "annotate", "clear_annotation":
+		return s.HandleAnnotate(evt)
 	}
 	return nil, nil
 }
 
 func (s *State) ToSGF(indexes bool) string {
-	result := "("
+	var sb strings.Builder
+	sb.WriteByte('(')
 	stack := []interface{}{s.Root}
 	for len(stack) > 0 {
 		i := len(stack) - 1
 		cur := stack[i]
 		stack = stack[:i]
 		if str, ok := cur.(string); ok {
-			result += str
+			sb.WriteString(str)
 			continue
 		}
 		node := cur.(*TreeNode)
-		result += ";"
+		sb.WriteByte(';')
 		// throw in other fields
 		for key, multifield := range node.Fields {
 			if key == "IX" {
 				continue
 			}
-			result += fmt.Sprintf("%s", key)
+			sb.WriteString(key)
 			for _, fieldValue := range multifield {
 				m := strings.ReplaceAll(fieldValue, "]", "\\]")
-				result += fmt.Sprintf("[%s]", m)
+				sb.WriteByte('[')
+				sb.WriteString(m)
+				sb.WriteByte(']')
 			}
 
 		}
 
 		if indexes {
-			result += fmt.Sprintf("IX[%d]", node.Index)
+			fmt.Fprintf(&sb, "IX[%d]", node.Index)
 		}
 
 		if len(node.Down) == 1 {
@@ -780,8 +1023,8 @@ func (s *State) ToSGF(indexes bool) string {
 		}
 	}
 
-	result += ")"
-	return result
+	sb.WriteByte(')')
+	return sb.String()
 }
 
 func FromSGF(data string) (*State, error) {
@@ -790,7 +1033,13 @@ func FromSGF(data string) (*State, error) {
 	if err != nil {
 		return nil, err
 	}
+	return FromSGFNode(root)
+}
 
+// FromSGFNode builds a state out of an already parsed tree
+// (which may have come from another format, see formats.go)
+func FromSGFNode(root *SGFNode) (*State, error) {
+	var err error
 	var size int64 = 19
 	if _, ok := root.Fields["SZ"]; ok {
 		size_field := root.Fields["SZ"]
@@ -804,6 +1053,10 @@ func FromSGF(data string) (*State, error) {
 	}
 
 	state := NewState(int(size), false)
+	if rules, ok := root.Fields["RU"]; ok && len(rules) > 0 {
+		state.Board.Rules = ParseRuleset(rules[0])
+	}
+
 	stack := []interface{}{root}
 	for len(stack) > 0 {
 		i := len(stack) - 1
@@ -825,9 +1078,12 @@ func FromSGF(data string) (*State, error) {
 				}
 			}
 
-			// refuse to process sgfs with a suicide move
-			if node.Coord() != nil && !state.Board.Legal(node.Coord(), node.Color()) {
-				return nil, fmt.Errorf("suicide moves are not currently supported")
+			// refuse to process sgfs with an illegal move
+			if coord := node.Coord(); coord != nil && !state.Board.Legal(coord, node.Color()) {
+				if state.Board.IsSuicide(coord, node.Color()) {
+					return nil, fmt.Errorf("suicide moves are not allowed under %s rules", state.Board.Rules)
+				}
+				return nil, fmt.Errorf("illegal move at %s", coord.ToLetters())
 			}
 
 			if node.IsPass() {
@@ -854,7 +1110,8 @@ func (s *State) InitData(event string) *EventJSON {
 	encoded := base64.StdEncoding.EncodeToString([]byte(sgf))
 	loc := s.Locate()
 	prefs := s.Prefs()
-	value := fmt.Sprintf("{\"sgf\":\"%s\", \"loc\":\"%s\", \"prefs\":%s, \"buffer\":%d, \"next_index\":%d}", encoded, loc, prefs, s.InputBuffer, s.NextIndex)
+	collapsed, _ := json.Marshal(s.CollapsedNodes())
+	value := fmt.Sprintf("{\"sgf\":\"%s\", \"loc\":\"%s\", \"prefs\":%s, \"buffer\":%d, \"next_index\":%d, \"collapsed\":%s}", encoded, loc, prefs, s.InputBuffer, s.NextIndex, collapsed)
 	evt := &EventJSON{event, value, 0, ""}
 	return evt
 
@@ -886,5 +1143,5 @@ func NewState(size int, initRoot bool) *State {
 	board := NewBoard(size)
 	// default input buffer of 250
 	// default room timeout of 86400
-	return &State{root, root, root, nodes, index, 250, 86400, size, board, nil}
+	return &State{root, root, root, nodes, index, 250, 86400, size, board, nil, nil, 0, false, nil, nil, nil}
 }
@@ -13,7 +13,9 @@ package main_test
 import (
 	//"fmt"
 	backend "github.com/jarednogo/board/backend"
+	"strings"
 	"testing"
+	"time"
 )
 
 func TestState1(t *testing.T) {
@@ -44,3 +46,801 @@ func TestState2(t *testing.T) {
 		t.Errorf("error with state to sgf (indexes), expected %d, got: %d", 132, len(sgfix))
 	}
 }
+
+func TestStateSuicide(t *testing.T) {
+	moves := ";B[ba];W[ca];B[ab];W[bb];B[];W[ac];B[aa]"
+	if _, err := backend.FromSGF("(;GM[1]SZ[19]RU[Japanese]" + moves + ")"); err == nil {
+		t.Errorf("expected error for suicide under japanese rules")
+	}
+
+	s, err := backend.FromSGF("(;GM[1]SZ[19]RU[NZ]" + moves + ")")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	if s.Board.Get(&backend.Coord{0, 0}) != backend.NoColor {
+		t.Errorf("suicide stone should have been removed")
+	}
+	if s.Board.Get(&backend.Coord{1, 0}) != backend.NoColor {
+		t.Errorf("suicided group should have been removed")
+	}
+}
+
+func TestCaptures(t *testing.T) {
+	// B[ab] captures aa, B[bc] captures bb, then AE takes cb off
+	input := "(;GM[1]SZ[9];W[bb];B[ba];W[aa];B[ab];W[];B[cb];W[];B[bc];AE[cb];W[ac])"
+	s, err := backend.FromSGF(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+
+	c := s.Captures()
+	if c.Black != 2 || c.White != 0 {
+		t.Errorf("expected 2 captured by black and 0 by white, got: %v", c)
+	}
+
+	// the setup node removing cb isn't a capture
+	s.Left()
+	if c := s.Captures(); c.Black != 2 {
+		t.Errorf("expected setup node not to count, got: %v", c)
+	}
+
+	// cutting B[bc] goes back to the earlier count
+	s.GotoIndex(8)
+	s.Cut()
+	if c := s.Captures(); c.Black != 1 {
+		t.Errorf("expected 1 capture after cut, got: %v", c)
+	}
+}
+
+func TestHandicapFixed(t *testing.T) {
+	s := backend.NewState(19, true)
+	if err := s.SetHandicap(9, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.Handicap() != 9 {
+		t.Errorf("expected handicap 9, got: %d", s.Handicap())
+	}
+	if len(s.Root.Fields["AB"]) != 9 {
+		t.Errorf("expected 9 handicap stones, got: %v", s.Root.Fields["AB"])
+	}
+	if s.Board.Get(&backend.Coord{3, 3}) != backend.Black {
+		t.Errorf("expected a stone on the star point")
+	}
+	if pl := s.Root.Fields["PL"]; len(pl) != 1 || pl[0] != "W" {
+		t.Errorf("expected PL[W], got: %v", pl)
+	}
+
+	// the handicap survives a round trip through sgf
+	s2, err := backend.FromSGF(s.ToSGF(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s2.Board.Get(&backend.Coord{9, 9}) != backend.Black {
+		t.Errorf("expected handicap stones after reloading")
+	}
+}
+
+func TestHandicapFree(t *testing.T) {
+	s := backend.NewState(19, true)
+	if err := s.SetHandicap(2, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Root.Fields["PL"]; ok {
+		t.Errorf("PL shouldn't be set before the stones are placed")
+	}
+
+	for _, c := range [][]interface{}{{2.0, 2.0}, {16.0, 16.0}} {
+		evt := &backend.EventJSON{"add_stone", c, 1, ""}
+		if _, err := s.AddEvent(evt); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if s.Current != s.Root || len(s.Root.Down) != 0 {
+		t.Errorf("handicap stones shouldn't create move nodes")
+	}
+	if len(s.Root.Fields["AB"]) != 2 {
+		t.Errorf("expected 2 handicap stones, got: %v", s.Root.Fields["AB"])
+	}
+	if pl := s.Root.Fields["PL"]; len(pl) != 1 || pl[0] != "W" {
+		t.Errorf("expected PL[W], got: %v", pl)
+	}
+
+	// the next stone is a normal move
+	evt := &backend.EventJSON{"add_stone", []interface{}{3.0, 15.0}, 2, ""}
+	if _, err := s.AddEvent(evt); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Root.Down) != 1 {
+		t.Errorf("expected a move node after placement")
+	}
+}
+
+func TestHandicapFreeAfterMoves(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Rewind()
+	s.FreeHandicap = 1
+	if _, err := s.PlaceHandicapStone(&backend.Coord{6, 6}); err == nil {
+		t.Errorf("expected no handicap stones under existing moves")
+	}
+	if len(s.Root.Fields["AB"]) != 0 {
+		t.Errorf("expected the root to be untouched, got: %v", s.Root.Fields["AB"])
+	}
+}
+
+func TestHandicapSettings(t *testing.T) {
+	room := backend.NewRoom()
+	settings := func(handicap float64, rules string) {
+		evt := &backend.EventJSON{"update_settings", map[string]interface{}{
+			"buffer":   0.0,
+			"size":     19.0,
+			"nickname": "alice",
+			"password": "",
+			"rules":    rules,
+			"handicap": handicap,
+		}, 0, "alice"}
+		room.HandleUpdateSettings(evt)
+	}
+
+	settings(3, "japanese")
+	if room.State.Handicap() != 3 {
+		t.Fatalf("expected handicap 3, got: %d", room.State.Handicap())
+	}
+	room.State.AddEvent(&backend.EventJSON{"add_stone", []interface{}{2.0, 2.0}, 2, ""})
+
+	// changing only the rules keeps the game
+	settings(3, "chinese")
+	if len(room.State.Root.Down) != 1 {
+		t.Errorf("expected the game to survive a rules change")
+	}
+
+	// a bad handicap leaves the game alone
+	settings(10, "chinese")
+	if len(room.State.Root.Down) != 1 || room.State.Handicap() != 3 {
+		t.Errorf("expected an invalid handicap to be rejected")
+	}
+}
+
+func settingsEvent(size, handicap float64) *backend.EventJSON {
+	return &backend.EventJSON{"update_settings", map[string]interface{}{
+		"buffer":   0.0,
+		"size":     size,
+		"nickname": "alice",
+		"password": "",
+		"handicap": handicap,
+	}, 0, "alice"}
+}
+
+func TestHandicapReload(t *testing.T) {
+	s := backend.NewState(19, true)
+	if err := s.SetHandicap(3, false); err != nil {
+		t.Fatal(err)
+	}
+	s.AddEvent(&backend.EventJSON{"add_stone", []interface{}{2.0, 2.0}, 2, ""})
+	room, err := backend.LoadRoom([]byte(s.InitData("handshake").Value.(string)))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// sending the handicap the game already has keeps the game
+	room.HandleUpdateSettings(settingsEvent(19, 3))
+	if len(room.State.Root.Down) != 1 {
+		t.Errorf("expected the reloaded game to survive the same handicap")
+	}
+}
+
+func TestHandicapTrash(t *testing.T) {
+	room := backend.NewRoom()
+	room.HandleUpdateSettings(settingsEvent(19, 3))
+	room.HandleTrash(&backend.EventJSON{"trash", nil, 0, "alice"})
+	if room.State.Handicap() != 0 {
+		t.Fatalf("expected no handicap after trashing")
+	}
+
+	// the same handicap can be set again
+	room.HandleUpdateSettings(settingsEvent(19, 3))
+	if room.State.Handicap() != 3 {
+		t.Errorf("expected handicap 3 again, got: %d", room.State.Handicap())
+	}
+
+	// and a size change after trashing doesn't bring it back
+	room.HandleTrash(&backend.EventJSON{"trash", nil, 0, "alice"})
+	evt := settingsEvent(13, 0)
+	delete(evt.Value.(map[string]interface{}), "handicap")
+	room.HandleUpdateSettings(evt)
+	if room.State.Size != 13 || room.State.Handicap() != 0 {
+		t.Errorf("expected a plain 13x13 board, got: %d with handicap %d", room.State.Size, room.State.Handicap())
+	}
+}
+
+func TestTranspositions(t *testing.T) {
+	// the same three moves in a different order
+	input := "(;GM[1]SZ[19](;B[pd];W[dd];B[pp])(;B[pp];W[dd];B[pd])(;B[pp];W[dp]))"
+	s, err := backend.FromSGF(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	first := s.Current.Index
+
+	tr := s.Transpositions()
+	if len(tr) != 1 {
+		t.Fatalf("expected 1 transposition, got: %v", tr)
+	}
+	if err := s.GotoTransposition(-1); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current.Index != tr[0] || s.Board.Get(&backend.Coord{15, 3}) != backend.Black {
+		t.Errorf("expected to land on the transposed node")
+	}
+	if err := s.GotoTransposition(-1); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current.Index != first {
+		t.Errorf("expected to cycle back to %d, got: %d", first, s.Current.Index)
+	}
+
+	// captured stones leave the hash too
+	s2, err := backend.FromSGF("(;GM[1]SZ[9];B[ba];W[aa];B[ab])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s2.FastForward()
+	if s2.Current.Hash != backend.ZobristHash(s2.Board) {
+		t.Errorf("incremental hash doesn't match the board")
+	}
+}
+
+func TestTranspositionsPass(t *testing.T) {
+	// a pass keeps the position of the node before it
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[];B[])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 4; i++ {
+		if tr := s.Transpositions(); len(tr) != 0 {
+			t.Errorf("expected no transpositions at depth %d, got: %v", s.Current.Depth(), tr)
+		}
+		s.Right()
+	}
+}
+
+func TestNextColor(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg];AB[ee]AW[ff];W[];PL[W]AB[aa])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []backend.Color{
+		backend.Black, // nothing played yet
+		backend.White, // after B[cc]
+		backend.Black, // after W[gg]
+		backend.Black, // setup doesn't change the turn
+		backend.Black, // after a white pass
+		backend.White, // PL[W]
+	}
+	for i, col := range want {
+		if got := s.NextColor(); got != col {
+			t.Errorf("node %d: expected %v, got: %v", i, col, got)
+		}
+		s.Right()
+	}
+
+	// a fixed handicap means white plays first
+	h := backend.NewState(19, true)
+	if err := h.SetHandicap(2, false); err != nil {
+		t.Fatal(err)
+	}
+	evt := &backend.EventJSON{"add_stone", []interface{}{2.0, 2.0}, 1, ""}
+	h.AssignColor(evt)
+	if evt.Color != int(backend.White) {
+		t.Errorf("expected white to play after handicap, got: %d", evt.Color)
+	}
+	frame, err := h.AddEvent(evt)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if frame.Turn != backend.Black {
+		t.Errorf("expected black's turn in the frame, got: %v", frame.Turn)
+	}
+}
+
+func TestReorder(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9](;B[aa];W[bb])(;B[cc](;W[dd])(;W[ee]))(;B[ff]))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	order := func(n *backend.TreeNode) string {
+		result := ""
+		for _, d := range n.Down {
+			result += strings.Join(d.Fields["B"], "") + strings.Join(d.Fields["W"], "")
+		}
+		return result
+	}
+	root := s.Root
+	cc := root.Down[1]
+	ee := cc.Down[1]
+
+	// W[ee]'s nearest fork is at B[cc]
+	if err := s.PromoteVariation(ee.Index); err != nil {
+		t.Fatal(err)
+	}
+	if order(cc) != "eedd" {
+		t.Errorf("expected ee first, got: %s", order(cc))
+	}
+
+	s.MoveBranch(cc.Index, -1)
+	if order(root) != "ccaaff" {
+		t.Errorf("expected cc moved up, got: %s", order(root))
+	}
+	s.MoveBranch(cc.Index, 1)
+	s.MoveBranch(cc.Index, 1)
+	if order(root) != "aaffcc" {
+		t.Errorf("expected cc moved to the end, got: %s", order(root))
+	}
+
+	// the preferred child follows the node it pointed to
+	s.GotoIndex(root.Down[1].Index)
+	if err := s.MakeMainline(cc.Down[1].Index); err != nil {
+		t.Fatal(err)
+	}
+	if order(root) != "ccaaff" || order(cc) != "ddee" {
+		t.Errorf("expected cc and dd on the main line, got: %s %s", order(root), order(cc))
+	}
+	if root.PreferredChild != 0 {
+		t.Errorf("expected the main line to be preferred")
+	}
+
+	sgf := s.ToSGF(false)
+	if !strings.Contains(sgf, "(;B[cc](;W[dd])(;W[ee]))(;B[aa]") {
+		t.Errorf("expected the sgf in the new order, got: %s", sgf)
+	}
+}
+
+func TestPaste(t *testing.T) {
+	input := "(;GM[1]SZ[9](;B[ba](;W[ff];B[ab])(;W[gg];B[ab]))(;W[aa];B[ff]))"
+	s, err := backend.FromSGF(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GotoIndex(s.Root.Down[0].Index)
+	if _, err := s.AddEvent(&backend.EventJSON{"copy", nil, 0, ""}); err != nil {
+		t.Fatal(err)
+	}
+
+	// paste after W[aa] B[ff]
+	dest := s.Root.Down[1].Down[0]
+	s.GotoIndex(dest.Index)
+	frame, err := s.AddEvent(&backend.EventJSON{"clipboard", nil, 0, ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	report := frame.Replay
+	if report == nil {
+		t.Fatal("expected a paste report")
+	}
+	if report.Kept != 3 {
+		t.Errorf("expected 3 nodes pasted, got: %d", report.Kept)
+	}
+	if len(report.Dropped) != 1 {
+		t.Fatalf("expected 1 dropped move, got: %d", len(report.Dropped))
+	}
+	d := report.Dropped[0]
+	if d.Reason != "occupied" || d.Following != 1 || d.Coord.X != 5 || d.Coord.Y != 5 {
+		t.Errorf("expected W[ff] dropped with 1 following, got: %v %s %d", d.Coord, d.Reason, d.Following)
+	}
+
+	// B[ab] now captures W[aa]
+	ba := dest.Down[0]
+	if len(ba.Down) != 1 {
+		t.Fatalf("expected 1 child of the pasted node, got: %d", len(ba.Down))
+	}
+	ab := ba.Down[0].Down[0]
+	if ab.Captures.Black != 1 {
+		t.Errorf("expected black to have captured 1 stone, got: %d", ab.Captures.Black)
+	}
+	s.GotoIndex(ab.Index)
+	if s.Board.Get(&backend.Coord{0, 0}) != backend.NoColor {
+		t.Errorf("expected W[aa] to be captured")
+	}
+	if ab.Hash != backend.ZobristHash(s.Board) {
+		t.Errorf("hash of the pasted node doesn't match the board")
+	}
+
+	// the clipboard is untouched
+	if len(s.Clipboard.Down) != 2 {
+		t.Errorf("expected the clipboard to keep both branches")
+	}
+}
+
+func TestEditNodes(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[ba];W[aa];B[ab];W[cc])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	line := func() string {
+		result := ""
+		for n := s.Root; len(n.Down) > 0; n = n.Down[n.PreferredChild] {
+			d := n.Down[n.PreferredChild]
+			result += strings.Join(d.Fields["B"], "") + strings.Join(d.Fields["W"], "")
+		}
+		return result
+	}
+	check := func() {
+		t.Helper()
+		if s.Current.Hash != backend.ZobristHash(s.Board) {
+			t.Errorf("board doesn't match the current node")
+		}
+	}
+	s.FastForward()
+	ba := s.Root.Down[0]
+	aa := ba.Down[0]
+	ab := aa.Down[0]
+
+	// deleting W[aa] keeps B[ab] and W[cc], and B[ab] captures nothing
+	if _, err := s.DeleteNode(aa.Index); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if line() != "baabcc" {
+		t.Errorf("expected ba ab cc, got: %s", line())
+	}
+	if _, ok := s.Nodes[aa.Index]; ok {
+		t.Errorf("expected the deleted node to be gone")
+	}
+	if ab.Up != ba || ab.Captures.Black != 0 || len(ab.Diff.Remove[0].Coords) != 0 {
+		t.Errorf("expected B[ab] replayed under B[ba] without a capture")
+	}
+
+	// inserting it again brings the capture back
+	s.GotoIndex(ba.Index)
+	if _, err := s.InsertNode(&backend.Coord{0, 0}, backend.White); err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if line() != "baaaabcc" || ab.Captures.Black != 1 {
+		t.Errorf("expected ba aa ab cc with a capture, got: %s", line())
+	}
+	if _, err := s.InsertNode(&backend.Coord{1, 0}, backend.White); err == nil {
+		t.Errorf("expected an occupied point to be refused")
+	}
+
+	// moving B[ab] to cc drops W[cc]
+	s.GotoIndex(ab.Index)
+	report, err := s.ReplaceMove(&backend.Coord{2, 2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	check()
+	if line() != "baaacc" || s.Current != ab {
+		t.Errorf("expected ba aa cc, got: %s", line())
+	}
+	if len(report.Dropped) != 1 || report.Dropped[0].Reason != "occupied" {
+		t.Errorf("expected W[cc] to be dropped")
+	}
+	if s.Board.Get(&backend.Coord{0, 0}) != backend.White {
+		t.Errorf("expected W[aa] to be back on the board")
+	}
+}
+
+func TestReplaceMoveExplorer(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[aa];W[bb];B[cc])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GotoIndex(s.Root.Down[0].Index)
+	if n := len(s.Explorer().Nodes); n != 4 {
+		t.Fatalf("expected 4 nodes, got: %d", n)
+	}
+
+	// moving B[aa] to bb drops the rest of the line
+	if _, err := s.ReplaceMove(&backend.Coord{1, 1}); err != nil {
+		t.Fatal(err)
+	}
+	if n := len(s.Explorer().Nodes); n != 2 {
+		t.Errorf("expected the dropped nodes to leave the explorer, got: %d nodes", n)
+	}
+}
+
+func TestNodeNames(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9](;B[cc];W[gg]N[Joseki])(;B[ee]))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ee := s.Root.Down[1]
+	s.GotoIndex(ee.Index)
+	frame, err := s.AddEvent(&backend.EventJSON{"set_node_name", " Key mistake [really] ", 0, ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(frame.Bookmarks) != 2 || frame.Bookmarks[0].Name != "Joseki" || frame.Bookmarks[1].Index != ee.Index {
+		t.Fatalf("expected 2 bookmarks in tree order, got: %v", frame.Bookmarks)
+	}
+	for _, node := range frame.Explorer.Nodes {
+		if node.Index == ee.Index && node.Name != "Key mistake [really]" {
+			t.Errorf("expected the name in the explorer, got: %q", node.Name)
+		}
+	}
+
+	// names survive a round trip through sgf
+	t2, err := backend.FromSGF(s.ToSGF(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	b := t2.Bookmarks()
+	if len(b) != 2 || b[1].Name != "Key mistake [really]" {
+		t.Errorf("expected the names to round trip, got: %v", b)
+	}
+
+	if _, err := s.AddEvent(&backend.EventJSON{"clear_node_name", nil, 0, ""}); err != nil {
+		t.Fatal(err)
+	}
+	if len(s.Bookmarks()) != 1 {
+		t.Errorf("expected 1 bookmark left")
+	}
+}
+
+func TestSearch(t *testing.T) {
+	input := "(;GM[1]SZ[9]C[start](;B[cc]C[a Joseki];W[gg]TR[aa])(;B[gg];W[cc]C[big mistake, this joseki is wrong]))"
+	s, err := backend.FromSGF(input)
+	if err != nil {
+		t.Fatal(err)
+	}
+	search := func(value map[string]interface{}) []*backend.SearchResult {
+		t.Helper()
+		q, err := backend.ParseSearch(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		results, err := s.Search(q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return results.Results
+	}
+
+	r := search(map[string]interface{}{"text": "joseki"})
+	if len(r) != 2 || r[0].Context != "a Joseki" || r[1].Depth != 2 {
+		t.Errorf("expected 2 comment matches, got: %v", r)
+	}
+	r = search(map[string]interface{}{"text": "^a J", "regex": true})
+	if len(r) != 1 || r[0].Index != s.Root.Down[0].Index {
+		t.Errorf("expected 1 regex match, got: %v", r)
+	}
+
+	// any branch, optionally by color
+	r = search(map[string]interface{}{"coord": []interface{}{6.0, 6.0}})
+	if len(r) != 2 {
+		t.Errorf("expected gg in both branches, got: %d", len(r))
+	}
+	r = search(map[string]interface{}{"coord": []interface{}{6.0, 6.0}, "color": 1.0})
+	if len(r) != 1 || r[0].Color != backend.Black {
+		t.Errorf("expected black gg only, got: %v", r)
+	}
+
+	r = search(map[string]interface{}{"marks": true})
+	if len(r) != 1 || r[0].Coord.X != 6 {
+		t.Errorf("expected the marked node, got: %v", r)
+	}
+
+	if _, err := backend.ParseSearch(map[string]interface{}{}); err == nil {
+		t.Errorf("expected an empty search to be refused")
+	}
+	q, _ := backend.ParseSearch(map[string]interface{}{"text": "(", "regex": true})
+	if _, err := s.Search(q); err == nil {
+		t.Errorf("expected a bad regex to be refused")
+	}
+}
+
+func TestSearchNonASCII(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// invalid utf-8 (latin-1) and characters that change length when lowered
+	s.Root.Down[0].Fields["C"] = []string{strings.Repeat("\xe9", 40) + " joseki"}
+	s.Root.Down[0].Down[0].Fields["C"] = []string{strings.Repeat("Ⱥ", 40) + " Joseki été"}
+	q, err := backend.ParseSearch(map[string]interface{}{"text": "JOSEKI"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	results, err := s.Search(q)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r := results.Results
+	if len(r) != 2 || !strings.HasSuffix(r[0].Context, " joseki") || !strings.Contains(r[1].Context, "Joseki été") {
+		t.Errorf("expected both comments to match, got: %v", r)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc](;W[aa];B[ba];W[ee];B[ab])(;W[gg];B[hh]))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	cc := s.Root.Down[0]
+	a := cc.Down[0].Down[0].Down[0].Down[0]
+	b := cc.Down[1].Down[0]
+
+	s.GotoIndex(a.Index)
+	frame, err := s.AddEvent(&backend.EventJSON{"compare", float64(b.Index), 0, ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	cmp := frame.Comparison
+	if cmp.Ancestor != cc.Index || len(cmp.MovesA) != 4 || len(cmp.MovesB) != 2 {
+		t.Fatalf("expected to split after B[cc] with 4 and 2 moves, got: %d %d %d", cmp.Ancestor, len(cmp.MovesA), len(cmp.MovesB))
+	}
+	letters := func(set *backend.StoneSet) string {
+		result := ""
+		for _, c := range set.Coords {
+			result += c.ToLetters()
+		}
+		return result
+	}
+	for _, check := range []struct {
+		set  *backend.StoneSet
+		want string
+	}{
+		{cmp.OnlyA[0], "baab"},
+		{cmp.OnlyA[1], "ee"},
+		{cmp.OnlyB[0], "hh"},
+		{cmp.OnlyB[1], "gg"},
+		{cmp.CapturedA[1], "aa"},
+		{cmp.CapturedB[1], ""},
+	} {
+		if got := letters(check.set); got != check.want {
+			t.Errorf("expected %q, got: %q", check.want, got)
+		}
+	}
+	if cmp.CapturesA.Black != 1 || cmp.CapturesB.Black != 0 {
+		t.Errorf("expected black to have captured once in A only")
+	}
+
+	// a node against its own ancestor
+	cmp, err = s.Compare(cc.Index, a.Index)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cmp.Ancestor != cc.Index || len(cmp.MovesA) != 0 {
+		t.Errorf("expected the ancestor to be B[cc] itself")
+	}
+}
+
+func TestAnnotations(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9]DM[1];B[cc]TE[2]GB[1]HO[1]V[3.5];W[gg])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Right()
+	a := s.GenerateMarks().Annotations
+	if a == nil || a.Move != "TE" || a.MoveEmphasis != 2 || a.Position != "GB" || a.Hotspot != 1 || *a.Value != 3.5 {
+		t.Fatalf("expected the imported annotations, got: %+v", a)
+	}
+	if a.Symbol() != "!!B*" {
+		t.Errorf("expected !!B*, got: %s", a.Symbol())
+	}
+	for _, node := range s.Explorer().Nodes {
+		if node.Index == s.Current.Index && node.Annotation != "!!B*" {
+			t.Errorf("expected the symbol in the explorer, got: %q", node.Annotation)
+		}
+	}
+
+	// a bad move replaces the tesuji
+	_, err = s.AddEvent(&backend.EventJSON{"annotate", map[string]interface{}{"key": "BM", "value": 1.0}, 0, ""})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.Current.Fields["TE"]; ok || s.Current.Annotations().Move != "BM" {
+		t.Errorf("expected BM instead of TE")
+	}
+	if !strings.Contains(s.ToSGF(false), "BM[1]") {
+		t.Errorf("expected BM in the sgf")
+	}
+
+	if err := s.ClearAnnotation(""); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current.Annotations() != nil {
+		t.Errorf("expected no annotations left")
+	}
+
+	// move annotations only go on moves
+	s.Rewind()
+	if err := s.Annotate("DO", 1); err == nil {
+		t.Errorf("expected DO on the root to be refused")
+	}
+	if err := s.Annotate("UC", 3); err == nil {
+		t.Errorf("expected a bad emphasis to be refused")
+	}
+	if err := s.ClearAnnotation("GM"); err == nil {
+		t.Errorf("expected clearing a non-annotation to be refused")
+	}
+}
+
+func TestCredits(t *testing.T) {
+	s := backend.NewState(9, true)
+	at := time.Unix(1735689600, 0)
+	evt := &backend.EventJSON{"add_stone", []interface{}{2.0, 2.0}, int(backend.Black), ""}
+	frame, err := s.AddEventBy(evt, "ali:ce", at)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.Current.Author() != "ali:ce" || len(frame.Credits) != 1 || frame.Credits[0].Time != at.Unix() {
+		t.Fatalf("expected the move credited to ali:ce, got: %v", frame.Credits)
+	}
+	for _, node := range frame.Explorer.Nodes {
+		if node.Index == s.Current.Index && node.Author != "ali:ce" {
+			t.Errorf("expected the author in the explorer, got: %q", node.Author)
+		}
+	}
+
+	// every comment and mark has its own credit
+	s.AddEventBy(&backend.EventJSON{"comment", "nice", 0, ""}, "bob", at)
+	s.AddEventBy(&backend.EventJSON{"comment", "very nice", 0, ""}, "carol", at.Add(time.Minute))
+	s.AddEventBy(&backend.EventJSON{"triangle", []interface{}{3.0, 3.0}, 0, ""}, "bob", at)
+	s.AddEventBy(&backend.EventJSON{"square", []interface{}{5.0, 5.0}, 0, ""}, "carol", at)
+	credits := s.Current.Credits()
+	if len(credits) != 5 || credits[1].Item != "0" || credits[1].Author != "bob" ||
+		credits[2].Item != "1" || credits[2].Author != "carol" || credits[2].Time != at.Unix()+60 {
+		t.Errorf("expected a credit for each comment, got: %v", credits)
+	}
+	if credits[3].Kind != "TR" || credits[3].Item != "dd" || credits[4].Kind != "SQ" || credits[4].Item != "ff" {
+		t.Errorf("expected a credit for each mark, got: %v", credits)
+	}
+
+	// taking a mark off takes its credit with it
+	s.AddEvent(&backend.EventJSON{"remove_mark", []interface{}{3.0, 3.0}, 0, ""})
+	for _, c := range s.Current.Credits() {
+		if c.Kind == "TR" {
+			t.Errorf("expected the triangle's credit to be gone, got: %v", c)
+		}
+	}
+	s.AddEventBy(&backend.EventJSON{"draw", []interface{}{0.0, 0.0, 1.0, 1.0, "red"}, 0, ""}, "bob", at)
+	s.AddEvent(&backend.EventJSON{"erase_pen", nil, 0, ""})
+	for _, c := range s.Current.Credits() {
+		if c.Kind == "PX" {
+			t.Errorf("expected the pen's credit to be gone, got: %v", c)
+		}
+	}
+	s.AddEvent(&backend.EventJSON{"remove_mark", []interface{}{5.0, 5.0}, 0, ""})
+
+	// events without an author don't credit anyone
+	s.AddEvent(&backend.EventJSON{"add_stone", []interface{}{4.0, 4.0}, int(backend.White), ""})
+	if len(s.Current.Credits()) != 0 {
+		t.Errorf("expected no credits")
+	}
+
+	// credits survive save and load
+	t2, err := backend.FromSGF(s.ToSGF(false))
+	if err != nil {
+		t.Fatal(err)
+	}
+	node := t2.Root.Down[0]
+	if node.Author() != "ali:ce" || len(node.Credits()) != 3 {
+		t.Errorf("expected the credits to round trip, got: %v", node.Credits())
+	}
+}
+
+func TestSuicideError(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9]RU[Japanese];B[ba];W[ca];B[ab];W[bb];B[];W[ac])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	_, err = s.AddEvent(&backend.EventJSON{"add_stone", []interface{}{0.0, 0.0}, int(backend.Black), ""})
+	if err == nil || !strings.Contains(err.Error(), "suicide") {
+		t.Errorf("expected a suicide error, got: %v", err)
+	}
+
+	// an occupied point is still ignored
+	if _, err := s.AddEvent(&backend.EventJSON{"add_stone", []interface{}{1.0, 0.0}, int(backend.White), ""}); err != nil {
+		t.Errorf("expected no error on an occupied point, got: %v", err)
+	}
+}
@@ -0,0 +1,338 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+/*
+a little tactical reading for the teaching overlay
+
+ladders: the attacker keeps giving atari, the defender keeps extending
+(or capturing an attacker stone in atari). the ladder works if the
+defender never gets to three liberties. when it fails, the stones that
+let the defender out are the breakers
+
+nets: a group with two liberties that can't be laddered, but where an
+attacker stone near its liberties leaves it with no way out
+
+snapbacks: a stone thrown in where it can be captured, after which
+playing on the same point again captures the capturing stones
+*/
+
+// how many positions a single reading may look at
+const readingBudget = 1000
+
+// a defender with this many liberties is out of the ladder,
+// nets get one more since the attacker doesn't have to give atari,
+// but the reading has to settle within a few moves
+const (
+	ladderEscape = 3
+	netEscape    = 4
+	netDepth     = 8
+)
+
+type Ladder struct {
+	Works    bool     `json:"works"`
+	Breakers []*Coord `json:"breakers"`
+}
+
+type Tactics struct {
+	Atari          []*Coord
+	Ladders        []*Coord
+	LadderBreakers []*Coord
+	Nets           []*Coord
+	Snapbacks      []*Coord
+}
+
+type reader struct {
+	start    *Board
+	original CoordSet
+	escape   int
+	// moves left to read in a line, running out counts as an escape
+	depth  int
+	budget int
+}
+
+// ReadLadder reads a ladder against the group at c
+// the group has to have one liberty (defender to move)
+// or two (attacker to move), otherwise the result is nil
+func ReadLadder(b *Board, c *Coord) *Ladder {
+	gp := b.FindGroup(c)
+	if gp.Color == NoColor {
+		return nil
+	}
+	r := &reader{b, gp.Coords, ladderEscape, b.Size * b.Size, readingBudget}
+	switch len(gp.Libs) {
+	case 1:
+		captured, breakers := r.defend(b, c)
+		return &Ladder{captured, breakers}
+	case 2:
+		captured, breakers := r.attack(b, c)
+		return &Ladder{captured, breakers}
+	}
+	return nil
+}
+
+// defend tries every way out for the group at c, with the defender to move
+func (r *reader) defend(b *Board, c *Coord) (bool, []*Coord) {
+	gp := b.FindGroup(c)
+	if len(gp.Libs) == 0 {
+		return true, nil
+	}
+	if r.budget <= 0 || r.depth <= 0 {
+		return false, nil
+	}
+	r.budget--
+	r.depth--
+	defer func() { r.depth++ }()
+
+	// capture an attacker stone in atari, or extend
+	options := []*Coord{}
+	seen := NewCoordSet()
+	for _, stone := range gp.Coords {
+		for _, nb := range b.Neighbors(stone) {
+			if b.Get(nb) != Opposite(gp.Color) {
+				continue
+			}
+			adj := b.FindGroup(nb)
+			if len(adj.Libs) == 1 {
+				for _, lib := range adj.Libs {
+					if !seen.Has(lib) {
+						seen.Add(lib)
+						options = append(options, lib)
+					}
+				}
+			}
+		}
+	}
+	for _, lib := range gp.Libs {
+		if !seen.Has(lib) {
+			options = append(options, lib)
+		}
+	}
+
+	var breakers []*Coord
+	for _, move := range options {
+		next := b.Copy()
+		if next.Move(move, gp.Color) == nil {
+			continue
+		}
+		libs := len(next.FindGroup(c).Libs)
+		if libs >= r.escape {
+			return false, r.breakers(next, c)
+		}
+		if libs >= 2 {
+			captured, bs := r.attack(next, c)
+			if !captured {
+				return false, bs
+			}
+			breakers = bs
+		}
+	}
+	return true, breakers
+}
+
+// attack tries filling each liberty of the group at c
+// (in a ladder there are two, and filling either one is atari)
+func (r *reader) attack(b *Board, c *Coord) (bool, []*Coord) {
+	gp := b.FindGroup(c)
+	if r.budget <= 0 || r.depth <= 0 {
+		return false, nil
+	}
+	r.budget--
+	r.depth--
+	defer func() { r.depth++ }()
+
+	var breakers []*Coord
+	for _, lib := range gp.Libs {
+		next := b.Copy()
+		if next.Move(lib, Opposite(gp.Color)) == nil {
+			continue
+		}
+		captured, bs := r.defend(next, c)
+		if captured {
+			return true, nil
+		}
+		if len(breakers) == 0 {
+			breakers = bs
+		}
+	}
+	return false, breakers
+}
+
+// breakers are the stones that were already on the board and helped
+// the defender escape: its own stones it connected to, and attacker
+// stones it captured
+func (r *reader) breakers(b *Board, c *Coord) []*Coord {
+	col := b.Get(c)
+	breakers := []*Coord{}
+	for _, stone := range b.FindGroup(c).Coords {
+		if r.start.Get(stone) == col && !r.original.Has(stone) {
+			breakers = append(breakers, stone)
+		}
+	}
+	for j := 0; j < b.Size; j++ {
+		for i := 0; i < b.Size; i++ {
+			p := &Coord{i, j}
+			if r.start.Get(p) == Opposite(col) && b.Get(p) == NoColor {
+				breakers = append(breakers, p)
+			}
+		}
+	}
+	return breakers
+}
+
+// findNet looks for an attacker move near the liberties of the group
+// at c (which has two) that leaves the group with no escape
+func findNet(b *Board, c *Coord) *Coord {
+	gp := b.FindGroup(c)
+	attacker := Opposite(gp.Color)
+	near := NewCoordSet()
+	for _, lib := range gp.Libs {
+		for _, nb := range b.Neighbors(lib) {
+			for _, nb2 := range b.Neighbors(nb) {
+				if b.Get(nb2) == NoColor && !gp.Libs.Has(nb2) {
+					near.Add(nb2)
+				}
+			}
+		}
+	}
+
+	// go in board order so the answer doesn't depend on map order
+	candidates := []*Coord{}
+	for j := 0; j < b.Size; j++ {
+		for i := 0; i < b.Size; i++ {
+			c := &Coord{i, j}
+			if near.Has(c) {
+				candidates = append(candidates, c)
+			}
+		}
+	}
+	for _, cand := range candidates {
+		next := b.Copy()
+		if next.Move(cand, attacker) == nil {
+			continue
+		}
+		if len(next.FindGroup(c).Libs) != 2 {
+			continue
+		}
+		r := &reader{next, gp.Coords, netEscape, netDepth, readingBudget}
+		if captured, _ := r.defend(next, c); captured {
+			return cand
+		}
+	}
+	return nil
+}
+
+// IsSnapback reports whether col playing at c throws in a stone
+// that, once captured, lets col take back more than one stone
+func IsSnapback(b *Board, c *Coord, col Color) bool {
+	next := b.Copy()
+	if next.Move(c, col) == nil {
+		return false
+	}
+	gp := next.FindGroup(c)
+	if gp.Color != col || len(gp.Coords) != 1 || len(gp.Libs) != 1 {
+		return false
+	}
+	// the opponent captures the thrown in stone
+	if next.Move(gp.Libs.List()[0], Opposite(col)) == nil {
+		return false
+	}
+	if next.Get(c) != NoColor {
+		return false
+	}
+	// and we take back more than one stone (so it isn't just a ko)
+	diff := next.Move(c, col)
+	if diff == nil {
+		return false
+	}
+	captured := 0
+	for _, r := range diff.Remove {
+		if r.Color == Opposite(col) {
+			captured += len(r.Coords)
+		}
+	}
+	return captured > 1
+}
+
+// ReadTactics finds everything the overlay shows for a position
+func ReadTactics(b *Board) *Tactics {
+	t := &Tactics{
+		Atari:          []*Coord{},
+		Ladders:        []*Coord{},
+		LadderBreakers: []*Coord{},
+		Nets:           []*Coord{},
+		Snapbacks:      []*Coord{},
+	}
+	breakers := NewCoordSet()
+	for _, gp := range b.Groups() {
+		stones := gp.Coords.List()
+		if len(gp.Libs) == 1 {
+			t.Atari = append(t.Atari, stones...)
+		}
+		// groups with no liberties only come from setup stones
+		if len(gp.Libs) == 0 || len(gp.Libs) > 2 {
+			continue
+		}
+		ladder := ReadLadder(b, stones[0])
+		if ladder == nil {
+			continue
+		}
+		if ladder.Works {
+			t.Ladders = append(t.Ladders, stones...)
+			continue
+		}
+		for _, c := range ladder.Breakers {
+			breakers.Add(c)
+		}
+		if len(gp.Libs) == 2 {
+			if net := findNet(b, stones[0]); net != nil {
+				t.Nets = append(t.Nets, net)
+			}
+		}
+	}
+	t.LadderBreakers = breakers.List()
+
+	// a snapback throws in next to an opponent group with two liberties
+	snapbacks := NewCoordSet()
+	for _, gp := range b.Groups() {
+		if len(gp.Libs) != 2 {
+			continue
+		}
+		for _, lib := range gp.Libs {
+			if !snapbacks.Has(lib) && IsSnapback(b, lib, Opposite(gp.Color)) {
+				snapbacks.Add(lib)
+			}
+		}
+	}
+	t.Snapbacks = snapbacks.List()
+	return t
+}
+
+// cachedTactics is what ReadTactics found at a node, along with the
+// hash of the position it read (which edits can change)
+type cachedTactics struct {
+	hash    uint64
+	tactics *Tactics
+}
+
+// CurrentTactics reads the tactics at the current node,
+// reusing what was read there before
+func (s *State) CurrentTactics() *Tactics {
+	if c, ok := s.tactics[s.Current]; ok && c.hash == s.Current.Hash {
+		return c.tactics
+	}
+	if s.tactics == nil {
+		s.tactics = make(map[*TreeNode]*cachedTactics)
+	}
+	t := ReadTactics(s.Board)
+	s.tactics[s.Current] = &cachedTactics{s.Current.Hash, t}
+	return t
+}
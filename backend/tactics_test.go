@@ -0,0 +1,135 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+// boardFromRows builds a board from a diagram,
+// X for black, O for white and anything else for empty
+func boardFromRows(rows []string) *backend.Board {
+	b := backend.NewBoard(len(rows))
+	for j, row := range rows {
+		for i, r := range row {
+			switch r {
+			case 'X':
+				b.Set(&backend.Coord{i, j}, backend.Black)
+			case 'O':
+				b.Set(&backend.Coord{i, j}, backend.White)
+			}
+		}
+	}
+	return b
+}
+
+// a white stone in atari that black can chase toward the upper right
+func ladderBoard() *backend.Board {
+	b := backend.NewBoard(19)
+	for _, c := range []*backend.Coord{{3, 14}, {2, 15}, {4, 16}, {3, 16}} {
+		b.Set(c, backend.Black)
+	}
+	b.Set(&backend.Coord{3, 15}, backend.White)
+	return b
+}
+
+func TestLadder(t *testing.T) {
+	ladder := backend.ReadLadder(ladderBoard(), &backend.Coord{3, 15})
+	if ladder == nil || !ladder.Works {
+		t.Fatalf("expected the ladder to work")
+	}
+
+	// a white stone in the ladder's path breaks it
+	b := ladderBoard()
+	b.Set(&backend.Coord{10, 8}, backend.White)
+	ladder = backend.ReadLadder(b, &backend.Coord{3, 15})
+	if ladder == nil || ladder.Works {
+		t.Fatalf("expected the ladder to fail")
+	}
+	if len(ladder.Breakers) != 1 || !ladder.Breakers[0].Equal(&backend.Coord{10, 8}) {
+		t.Errorf("expected the breaker at (10, 8), got: %v", ladder.Breakers)
+	}
+
+	// and a white stone off to the side doesn't
+	b = ladderBoard()
+	b.Set(&backend.Coord{3, 3}, backend.White)
+	if ladder = backend.ReadLadder(b, &backend.Coord{3, 15}); !ladder.Works {
+		t.Errorf("expected the ladder to still work")
+	}
+}
+
+func TestTactics(t *testing.T) {
+	// white throws in at (0, 0) for a snapback
+	b := boardFromRows([]string{
+		"..O......",
+		"XXO......",
+		"OO.......",
+		".........",
+		"......X..",
+		".........",
+		".........",
+		".........",
+		".........",
+	})
+	tactics := backend.ReadTactics(b)
+	if len(tactics.Snapbacks) != 1 || !tactics.Snapbacks[0].Equal(&backend.Coord{0, 0}) {
+		t.Errorf("expected a snapback at (0, 0), got: %v", tactics.Snapbacks)
+	}
+	if len(tactics.Atari) != 0 {
+		t.Errorf("expected nothing in atari, got: %v", tactics.Atari)
+	}
+
+	// after the throw in, black is in atari
+	b.Set(&backend.Coord{0, 0}, backend.White)
+	tactics = backend.ReadTactics(b)
+	if len(tactics.Atari) != 3 {
+		t.Errorf("expected 3 stones in atari, got: %v", tactics.Atari)
+	}
+}
+
+func TestTacticsDeadSetup(t *testing.T) {
+	// setup stones can leave a group with no liberties
+	s, err := backend.FromSGF("(;GM[1]SZ[9]AB[aa]AW[ab][ba])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.Tactics = true
+	marks := s.GenerateMarks()
+	if len(marks.Atari) != 0 {
+		t.Errorf("expected nothing in atari, got: %v", marks.Atari)
+	}
+}
+
+func TestNet(t *testing.T) {
+	// (7, 6) breaks the ladder on the white stone,
+	// but black can still net it
+	b := boardFromRows([]string{
+		".........",
+		".........",
+		".........",
+		"....XX...",
+		"...XO....",
+		"...X.....",
+		".......O.",
+		".........",
+		".........",
+	})
+	tactics := backend.ReadTactics(b)
+	for _, c := range tactics.Ladders {
+		if c.Equal(&backend.Coord{4, 4}) {
+			t.Fatalf("expected the ladder to fail")
+		}
+	}
+	if len(tactics.Nets) != 1 || !tactics.Nets[0].Equal(&backend.Coord{6, 5}) {
+		t.Errorf("expected a net at (6, 5), got: %v", tactics.Nets)
+	}
+}
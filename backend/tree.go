@@ -10,6 +10,10 @@ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLI
 
 package main
 
+import (
+	"fmt"
+)
+
 func Fmap(f func(*TreeNode), root *TreeNode) {
 	stack := []*TreeNode{root}
 	for len(stack) > 0 {
@@ -37,6 +41,19 @@ type TreeNode struct {
 	PreferredChild int
 	Fields         map[string][]string
 	Diff           *Diff
+	Captures       Captures
+	Hash           uint64
+	// hides the subtree below this node in the explorer
+	Collapsed bool
+	// read from XA when the node is made, see Credits
+	credits []*Credit
+}
+
+// Captures counts the stones captured on the way to a node
+// Black is the number of stones black has captured (i.e. white stones)
+type Captures struct {
+	Black int `json:"black"`
+	White int `json:"white"`
 }
 
 func NewTreeNode(coord *Coord, col Color, index int, up *TreeNode, fields map[string][]string) *TreeNode {
@@ -44,7 +61,62 @@ func NewTreeNode(coord *Coord, col Color, index int, up *TreeNode, fields map[st
 		fields = make(map[string][]string)
 	}
 	down := []*TreeNode{}
-	return &TreeNode{coord, col, down, up, index, 0, fields, nil}
+	return &TreeNode{coord, col, down, up, index, 0, fields, nil, Captures{}, 0, false, parseCredits(fields)}
+}
+
+// CountCaptures sets the node's captures from its parent's and its own diff
+// setup nodes (AE) take stones off the board but don't capture anything
+func (n *TreeNode) CountCaptures() {
+	c := Captures{}
+	if n.Up != nil {
+		c = n.Up.Captures
+	}
+	if n.XY != nil && n.Diff != nil {
+		for _, r := range n.Diff.Remove {
+			if r.Color == White {
+				c.Black += len(r.Coords)
+			} else if r.Color == Black {
+				c.White += len(r.Coords)
+			}
+		}
+	}
+	n.Captures = c
+}
+
+// MoveChild moves the child at index from to index to,
+// shifting the others along, and keeps the same child preferred
+func (n *TreeNode) MoveChild(from, to int) {
+	if from < 0 || to < 0 || from >= len(n.Down) || to >= len(n.Down) || from == to {
+		return
+	}
+	preferred := n.Down[n.PreferredChild]
+	child := n.Down[from]
+	n.Down = append(n.Down[:from], n.Down[from+1:]...)
+	n.Down = append(n.Down[:to], append([]*TreeNode{child}, n.Down[to:]...)...)
+	for i, d := range n.Down {
+		if d == preferred {
+			n.PreferredChild = i
+		}
+	}
+}
+
+// ChildIndex is the position of a child in Down, or -1
+func (n *TreeNode) ChildIndex(child *TreeNode) int {
+	for i, d := range n.Down {
+		if d == child {
+			return i
+		}
+	}
+	return -1
+}
+
+// Depth is the number of nodes above this one
+func (n *TreeNode) Depth() int {
+	d := 0
+	for cur := n.Up; cur != nil; cur = cur.Up {
+		d++
+	}
+	return d
 }
 
 func (n *TreeNode) Copy() *TreeNode {
@@ -104,18 +176,58 @@ func (n *TreeNode) RemoveField(key, value string) {
 	}
 }
 
+// FillGrid lays out the whole tree, apart from collapsed subtrees
 func (n *TreeNode) FillGrid(currentIndex int) *Explorer {
+	return n.Layout(nil).Explorer(currentIndex, 0)
+}
+
+// FillWindow lays out the part of the tree within size columns and rows
+// of the current node (size 0 means the whole tree)
+// collapsed nodes on the way to the current node stay open
+func (n *TreeNode) FillWindow(current *TreeNode, size int) *Explorer {
+	return n.Layout(current).Explorer(current.Index, size)
+}
+
+// openPath is the collapsed nodes on the way to the current node,
+// which get laid out open
+func openPath(current *TreeNode) string {
+	open := []int{}
+	for cur := current; cur != nil; cur = cur.Up {
+		if cur.Collapsed {
+			open = append(open, cur.Index)
+		}
+	}
+	return fmt.Sprint(open)
+}
+
+// Grid is where every node of a tree goes in the explorer
+// it's worked out for the whole tree at once, so a window cut out of
+// it puts every node in the same row and column as the full tree does
+type Grid struct {
+	root   *TreeNode
+	loc    map[int][2]int
+	nodes  map[int]*TreeNode
+	hidden map[int]int
+	// see openPath
+	open string
+}
+
+// Layout works out the grid of the tree below n
+// collapsed nodes on the way to current stay open (current can be nil)
+func (n *TreeNode) Layout(current *TreeNode) *Grid {
+	path := make(map[int]bool)
+	for cur := current; cur != nil; cur = cur.Up {
+		path[cur.Index] = true
+	}
+
 	stack := []interface{}{n}
 	x := 0
 	y := 0
 	gridLen := 1
 	grid := make(map[[2]int]int)
 	loc := make(map[int][2]int)
-	colors := make(map[int]Color)
-	parents := make(map[int]int)
-	prefs := make(map[int]int)
-	var currentCoord *Coord
-	var currentColor Color
+	nodes := make(map[int]*TreeNode)
+	hidden := make(map[int]int)
 	for len(stack) > 0 {
 		// pop off the stack
 		cur := stack[len(stack)-1]
@@ -127,13 +239,7 @@ func (n *TreeNode) FillGrid(currentIndex int) *Explorer {
 		}
 
 		node := cur.(*TreeNode)
-		colors[node.Index] = node.Color
-		if node.Up != nil {
-			parents[node.Index] = node.Up.Index
-		}
-		if len(node.Down) > 0 {
-			prefs[node.Index] = node.Down[node.PreferredChild].Index
-		}
+		nodes[node.Index] = node
 
 		y = gridLen - 1
 
@@ -175,11 +281,6 @@ func (n *TreeNode) FillGrid(currentIndex int) *Explorer {
 		grid[[2]int{y, x}] = node.Index
 		loc[node.Index] = [2]int{x, y}
 
-		if node.Index == currentIndex {
-			currentCoord = &Coord{x, y}
-			currentColor = node.Color
-		}
-
 		// if the parent is a diagonal away, we have to take up
 		// another node
 		// (this is for all the "angled" edges")
@@ -195,50 +296,86 @@ func (n *TreeNode) FillGrid(currentIndex int) *Explorer {
 		}
 		x++
 
+		// nothing below a collapsed node
+		if node.Collapsed && !path[node.Index] {
+			Fmap(func(*TreeNode) { hidden[node.Index]++ }, node)
+			hidden[node.Index]--
+			continue
+		}
+
 		// push on children in reverse order
 		for i := len(node.Down) - 1; i >= 0; i-- {
 			stack = append(stack, "")
 			stack = append(stack, node.Down[i])
 		}
 	}
+	return &Grid{n, loc, nodes, hidden, openPath(current)}
+}
+
+// Explorer cuts the part within size columns and rows of the current
+// node out of the grid (size 0 means the whole grid)
+// colors, names and so on are read off the nodes, since they can
+// change without the layout changing
+func (g *Grid) Explorer(currentIndex int, size int) *Explorer {
+	var currentCoord *Coord
+	var currentColor Color
+	if l, ok := g.loc[currentIndex]; ok {
+		currentCoord = &Coord{l[0], l[1]}
+		currentColor = g.nodes[currentIndex].Color
+	}
+
+	// only send what's in the window
+	visible := func(l [2]int) bool {
+		if size == 0 || currentCoord == nil {
+			return true
+		}
+		dx := l[0] - currentCoord.X
+		dy := l[1] - currentCoord.Y
+		return dx >= -size && dx <= size && dy >= -size && dy <= size
+	}
+
+	gridNode := func(i int) *GridNode {
+		node := g.nodes[i]
+		l := g.loc[i]
+		_, collapsed := g.hidden[i]
+		return &GridNode{&Coord{l[0], l[1]}, node.Color, i, collapsed, g.hidden[i], node.NodeName(), node.Annotations().Symbol(), node.Author()}
+	}
 
 	nodes := []*GridNode{}
 	edges := []*GridEdge{}
-	for i, l := range loc {
+	for i, l := range g.loc {
+		if !visible(l) {
+			continue
+		}
+
 		// gather all the nodes with their color attached
-		x := l[0]
-		y := l[1]
-		gridNode := &GridNode{&Coord{x, y}, colors[i], i}
-		nodes = append(nodes, gridNode)
+		nodes = append(nodes, gridNode(i))
 
 		// gather all the edges
-		p, ok := parents[i]
-		if !ok {
+		p := g.nodes[i].Up
+		if i == g.root.Index || p == nil {
 			continue
 		}
-		pCoord := loc[p]
+		pCoord := g.loc[p.Index]
 		start := &Coord{pCoord[0], pCoord[1]}
-		end := &Coord{x, y}
+		end := &Coord{l[0], l[1]}
 		edge := &GridEdge{start, end}
 		edges = append(edges, edge)
 	}
 
 	preferredNodes := []*GridNode{}
-	index := 0
-	for {
-		if l, ok := loc[index]; ok {
-			x := l[0]
-			y := l[1]
-			gridNode := &GridNode{&Coord{x, y}, colors[index], index}
-			preferredNodes = append(preferredNodes, gridNode)
-
-			if index, ok = prefs[index]; !ok {
-				break
-			}
-
-		} else {
+	for node := g.root; node != nil; {
+		l, ok := g.loc[node.Index]
+		if !ok {
+			break
+		}
+		if visible(l) {
+			preferredNodes = append(preferredNodes, gridNode(node.Index))
+		}
+		if len(node.Down) == 0 {
 			break
 		}
+		node = node.Down[node.PreferredChild]
 	}
 
 	return &Explorer{
@@ -254,6 +391,15 @@ type GridNode struct {
 	Coord *Coord `json:"coord"`
 	Color `json:"color"`
 	Index int `json:"index"`
+	// collapsed nodes say how many nodes they hide
+	Collapsed bool `json:"collapsed"`
+	Hidden    int  `json:"hidden"`
+	// the node's N[] property
+	Name string `json:"name"`
+	// its annotations (see Annotations.Symbol)
+	Annotation string `json:"annotation"`
+	// whoever made the node
+	Author string `json:"author"`
 }
 
 type GridEdge struct {
@@ -11,7 +11,9 @@ THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLI
 package main_test
 
 import (
+	"fmt"
 	backend "github.com/jarednogo/board/backend"
+	"strings"
 	"testing"
 )
 
@@ -45,3 +47,143 @@ func TestTree(t *testing.T) {
 		}
 	}
 }
+
+func TestCollapse(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[19](;B[pd];W[dd];B[pp];W[dp])(;B[dd];W[ee]))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	pd := s.Root.Down[0]
+	if err := s.Collapse(pd.Index, true); err != nil {
+		t.Fatal(err)
+	}
+	explorer := s.Explorer()
+	if len(explorer.Nodes) != 4 || len(explorer.Edges) != 3 {
+		t.Errorf("expected 4 nodes and 3 edges, got: %d %d", len(explorer.Nodes), len(explorer.Edges))
+	}
+	for _, node := range explorer.Nodes {
+		if node.Index == pd.Index && (!node.Collapsed || node.Hidden != 3) {
+			t.Errorf("expected B[pd] to hide 3 nodes, got: %v %d", node.Collapsed, node.Hidden)
+		}
+	}
+
+	// the way to the current node stays open
+	s.GotoIndex(pd.Down[0].Down[0].Index)
+	if n := len(s.Explorer().Nodes); n != 7 {
+		t.Errorf("expected the whole tree, got: %d nodes", n)
+	}
+
+	// collapsing above the current node moves up to it
+	s.Collapse(pd.Index, false)
+	if err := s.Collapse(pd.Index, true); err != nil {
+		t.Fatal(err)
+	}
+	if s.Current != pd {
+		t.Errorf("expected to move up to the collapsed node")
+	}
+
+	// and it's saved
+	if c := s.CollapsedNodes(); len(c) != 1 || c[0] != pd.Index {
+		t.Errorf("expected [%d], got: %v", pd.Index, c)
+	}
+	if !strings.Contains(s.InitData("handshake").Value.(string), fmt.Sprintf("\"collapsed\":[%d]", pd.Index)) {
+		t.Errorf("expected collapsed nodes in the saved data")
+	}
+	s.ExpandAll()
+	if len(s.CollapsedNodes()) != 0 {
+		t.Errorf("expected nothing collapsed")
+	}
+}
+
+func TestExplorerWindow(t *testing.T) {
+	sgf := "(;GM[1]SZ[19]"
+	for i := 0; i < 20; i++ {
+		sgf += fmt.Sprintf(";B[%c%c]", 'a'+i%19, 'a'+i/19)
+	}
+	sgf += ")"
+	s, err := backend.FromSGF(sgf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 10; i++ {
+		s.Right()
+	}
+	explorer := s.ExplorerAt(s.Current, 3)
+	if len(explorer.Nodes) != 7 || len(explorer.PreferredNodes) != 7 {
+		t.Fatalf("expected 7 nodes, got: %d", len(explorer.Nodes))
+	}
+	for _, node := range explorer.Nodes {
+		if node.Coord.X < 7 || node.Coord.X > 13 {
+			t.Errorf("node outside the window: %v", node.Coord)
+		}
+	}
+	if explorer.Current.X != 10 {
+		t.Errorf("expected the current node at 10, got: %d", explorer.Current.X)
+	}
+}
+
+func TestExplorerWindowLayout(t *testing.T) {
+	// branches at different depths, so rows get shared between them
+	s, err := backend.FromSGF("(;GM[1]SZ[19]" +
+		"(;B[aa];W[ba](;B[ca];W[da];B[ea];W[fa](;B[ga];W[ha])(;B[gb]))(;B[cb];W[db](;B[eb])(;B[ec])))" +
+		"(;B[ab](;W[bb];B[cc])(;W[bc])))")
+	if err != nil {
+		t.Fatal(err)
+	}
+	full := make(map[int]*backend.Coord)
+	for _, node := range s.Root.FillGrid(0).Nodes {
+		full[node.Index] = node.Coord
+	}
+
+	for index, n := range s.Nodes {
+		for size := 1; size <= 3; size++ {
+			explorer := s.ExplorerAt(n, size)
+			for _, node := range explorer.Nodes {
+				want := full[node.Index]
+				if node.Coord.X != want.X || node.Coord.Y != want.Y {
+					t.Errorf("window %d around %d: node %d at %v, full layout at %v",
+						size, index, node.Index, node.Coord, want)
+				}
+			}
+			if want := full[index]; explorer.Current.X != want.X || explorer.Current.Y != want.Y {
+				t.Errorf("window %d around %d: current at %v, full layout at %v",
+					size, index, explorer.Current, want)
+			}
+		}
+	}
+}
+
+func TestExplorerWindowPerConnection(t *testing.T) {
+	sgf := "(;GM[1]SZ[19]"
+	for i := 0; i < 20; i++ {
+		sgf += fmt.Sprintf(";B[%c%c]", 'a'+i%19, 'a'+i/19)
+	}
+	sgf += ")"
+	room := backend.NewRoom()
+	s, err := backend.FromSGF(sgf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	room.State = s
+	room.HandleExplorerWindow(&backend.EventJSON{"explorer_window", 2.0, 0, "alice"})
+
+	// navigating brings new nodes into alice's window,
+	// while bob already has the whole tree
+	var bcast *backend.EventJSON
+	for i := 0; i < 10; i++ {
+		bcast = room.HandleEvent(&backend.EventJSON{"right", nil, 0, "alice"})
+	}
+	frame := bcast.Value.(*backend.Frame)
+	alice := room.FrameFor("alice", frame).Explorer
+	if len(alice.Nodes) != 5 {
+		t.Fatalf("expected 5 nodes around the current node, got: %d", len(alice.Nodes))
+	}
+	for _, node := range alice.Nodes {
+		if node.Coord.X < 8 || node.Coord.X > 12 {
+			t.Errorf("node outside alice's window: %v", node.Coord)
+		}
+	}
+	if bob := room.FrameFor("bob", frame).Explorer; bob.Nodes != nil {
+		t.Errorf("expected bob's window to be left alone, got: %d nodes", len(bob.Nodes))
+	}
+}
@@ -0,0 +1,60 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+/*
+whose turn it is comes from the tree:
+PL on a node says who plays next, otherwise it's the opposite of the
+last move on the way up (setup nodes don't change it), and black plays
+first in a game with no moves
+
+in auto color mode the server uses this instead of the color the
+client sent with add_stone and pass
+place_stone always uses the client's color, for editing positions
+*/
+
+// ParsePL reads the value of a PL field
+func ParsePL(value string) Color {
+	switch strings.ToUpper(strings.TrimSpace(value)) {
+	case "B", "BLACK", "1":
+		return Black
+	case "W", "WHITE", "2":
+		return White
+	}
+	return NoColor
+}
+
+// NextColor is the color to play at the current node
+func (s *State) NextColor() Color {
+	for n := s.Current; n != nil; n = n.Up {
+		if pl, ok := n.Fields["PL"]; ok && len(pl) > 0 {
+			if col := ParsePL(pl[0]); col != NoColor {
+				return col
+			}
+		}
+		if n.Color == Black || n.Color == White {
+			return Opposite(n.Color)
+		}
+	}
+	return Black
+}
+
+// AssignColor sets the color of a move event to the color to play
+func (s *State) AssignColor(evt *EventJSON) {
+	switch evt.Event {
+	case "add_stone", "pass", "insert_move", "insert_pass":
+		evt.Color = int(s.NextColor())
+	}
+}
@@ -83,6 +83,7 @@ type LoadJSON struct {
 	Buffer    int64          `json:"buffer"`
 	NextIndex int            `json:"next_index"`
 	Password  string         `json:"password"`
+	Collapsed []int          `json:"collapsed"`
 }
 
 type EventJSON struct {
@@ -0,0 +1,194 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"strings"
+)
+
+/*
+variants are a room setting, so they survive uploads and trashing
+and never end up in the sgf
+
+- atari-go: whoever captures first wins, and the game stops there
+- one-color: everyone sees every stone as black
+- blind: nobody sees the stones at all
+
+spectators (e.g. the teacher) always see the real board
+*/
+
+type Variant int
+
+const (
+	NormalGo Variant = iota
+	AtariGo
+	OneColor
+	Blind
+)
+
+func (v Variant) String() string {
+	switch v {
+	case AtariGo:
+		return "atari-go"
+	case OneColor:
+		return "one-color"
+	case Blind:
+		return "blind"
+	}
+	return "normal"
+}
+
+// ParseVariant maps a variant name from the settings to a variant
+// anything unrecognized is normal go
+func ParseVariant(s string) Variant {
+	t := strings.ToLower(strings.TrimSpace(s))
+	t = strings.ReplaceAll(t, "-", " ")
+	t = strings.ReplaceAll(t, "_", " ")
+	switch t {
+	case "atari go", "atarigo", "atari", "capture go", "first capture":
+		return AtariGo
+	case "one color", "one colour", "onecolor", "onecolour":
+		return OneColor
+	case "blind", "blindfold":
+		return Blind
+	}
+	return NormalGo
+}
+
+// Masks reports whether players get a different view than spectators
+func (v Variant) Masks() bool {
+	return v == OneColor || v == Blind
+}
+
+// Mask hides what a variant doesn't let players see
+// the frame passed in is left alone
+func (v Variant) Mask(frame *Frame) *Frame {
+	if frame == nil || !v.Masks() {
+		return frame
+	}
+	masked := *frame
+	// comparisons give away the colors of both positions,
+	// and scores, estimates and the tactics overlay are all
+	// worked out from the real colors
+	masked.Comparison = nil
+	masked.Score = nil
+	masked.Estimate = nil
+	if frame.Marks != nil {
+		marks := *frame.Marks
+		marks.BlackTerritory = nil
+		marks.WhiteTerritory = nil
+		marks.Dead = nil
+		marks.Atari = nil
+		marks.Ladders = nil
+		marks.LadderBreakers = nil
+		marks.Nets = nil
+		marks.Snapbacks = nil
+		masked.Marks = &marks
+	}
+	if frame.Metadata != nil {
+		masked.Metadata = v.maskMetadata(frame.Metadata)
+	}
+	switch v {
+	case OneColor:
+		if frame.Diff != nil {
+			masked.Diff = NewDiff(
+				recolor(frame.Diff.Add, Black),
+				recolor(frame.Diff.Remove, Black))
+		}
+		if frame.Explorer != nil {
+			explorer := *frame.Explorer
+			explorer.Nodes = recolorNodes(explorer.Nodes, Black)
+			explorer.PreferredNodes = recolorNodes(explorer.PreferredNodes, Black)
+			if explorer.CurrentColor != NoColor {
+				explorer.CurrentColor = Black
+			}
+			masked.Explorer = &explorer
+		}
+	case Blind:
+		if frame.Diff != nil {
+			masked.Diff = NewDiff([]*StoneSet{}, []*StoneSet{})
+		}
+	}
+	return &masked
+}
+
+// maskMetadata hides the colors of setup stones (and handicap stones)
+// in the root fields
+// one color shows them all as black, and blind doesn't show them
+func (v Variant) maskMetadata(metadata *Metadata) *Metadata {
+	masked := *metadata
+	masked.Fields = make(map[string][]string)
+	for key, value := range metadata.Fields {
+		if key != "AB" && key != "AW" {
+			masked.Fields[key] = value
+		}
+	}
+	if v == OneColor {
+		stones := append([]string{}, metadata.Fields["AB"]...)
+		stones = append(stones, metadata.Fields["AW"]...)
+		if len(stones) > 0 {
+			masked.Fields["AB"] = stones
+		}
+	}
+	return &masked
+}
+
+// playsMove reports whether an event adds a move to the game
+func playsMove(event string) bool {
+	switch event {
+	case "add_stone", "place_stone", "pass", "insert_move", "insert_pass", "replace_move":
+		return true
+	}
+	return false
+}
+
+func recolor(sets []*StoneSet, col Color) []*StoneSet {
+	if sets == nil {
+		return nil
+	}
+	result := []*StoneSet{}
+	for _, set := range sets {
+		result = append(result, &StoneSet{set.Coords, col})
+	}
+	return result
+}
+
+func recolorNodes(nodes []*GridNode, col Color) []*GridNode {
+	if nodes == nil {
+		return nil
+	}
+	result := []*GridNode{}
+	for _, node := range nodes {
+		c := node.Color
+		if c != NoColor {
+			c = col
+		}
+		result = append(result, &GridNode{node.Coord, c, node.Index, node.Collapsed, node.Hidden, node.Name, node.Annotation, node.Author})
+	}
+	return result
+}
+
+// FirstCapture finds who captured first on the way to the current node
+// (which is the winner in atari-go), or NoColor if nobody has
+func (s *State) FirstCapture() Color {
+	winner := NoColor
+	for n := s.Current; n != nil; n = n.Up {
+		if n.XY == nil || n.Diff == nil {
+			continue
+		}
+		for _, r := range n.Diff.Remove {
+			if r.Color == Opposite(n.Color) && len(r.Coords) > 0 {
+				winner = n.Color
+			}
+		}
+	}
+	return winner
+}
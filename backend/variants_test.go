@@ -0,0 +1,157 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main_test
+
+import (
+	backend "github.com/jarednogo/board/backend"
+	"testing"
+)
+
+func TestParseVariant(t *testing.T) {
+	for input, want := range map[string]backend.Variant{
+		"Atari-Go":   backend.AtariGo,
+		"capture go": backend.AtariGo,
+		"one-colour": backend.OneColor,
+		"blind":      backend.Blind,
+		"":           backend.NormalGo,
+	} {
+		if got := backend.ParseVariant(input); got != want {
+			t.Errorf("%q: expected %v, got: %v", input, want, got)
+		}
+	}
+}
+
+func TestFirstCapture(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[ba];W[aa];B[ab];W[ee];B[dd])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.GotoIndex(2)
+	if c := s.FirstCapture(); c != backend.NoColor {
+		t.Errorf("expected no capture yet, got: %v", c)
+	}
+	s.FastForward()
+	if c := s.FirstCapture(); c != backend.Black {
+		t.Errorf("expected black to have captured first, got: %v", c)
+	}
+}
+
+func TestMask(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	frame := s.GenerateFullFrame(true)
+
+	oneColor := backend.OneColor.Mask(frame)
+	for _, set := range oneColor.Diff.Add {
+		if len(set.Coords) > 0 && set.Color != backend.Black {
+			t.Errorf("expected every stone to look black, got: %v", set)
+		}
+	}
+	if frame.Diff.Add[1].Color != backend.White {
+		t.Errorf("masking shouldn't change the original frame")
+	}
+
+	blind := backend.Blind.Mask(frame)
+	if len(blind.Diff.Add) != 0 || len(blind.Diff.Remove) != 0 {
+		t.Errorf("expected no stones in a blind frame, got: %v", blind.Diff)
+	}
+	if backend.NormalGo.Mask(frame) != frame {
+		t.Errorf("expected normal go to leave frames alone")
+	}
+}
+
+func TestMaskDerived(t *testing.T) {
+	// the white stone at aa is in atari and gg is marked dead
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[cc];W[gg];B[ba];W[aa])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.FastForward()
+	s.Tactics = true
+	s.StartScoring()
+	s.ToggleDead(&backend.Coord{6, 6})
+	frame := s.GenerateFullFrame(true)
+	frame.Estimate = s.EstimateScore()
+	if frame.Score == nil || len(frame.Marks.Dead) == 0 || len(frame.Marks.Atari) == 0 {
+		t.Fatalf("expected a score, dead stones and ataris to mask")
+	}
+
+	for _, v := range []backend.Variant{backend.OneColor, backend.Blind} {
+		masked := v.Mask(frame)
+		if masked.Score != nil || masked.Estimate != nil {
+			t.Errorf("%v: expected no score or estimate", v)
+		}
+		m := masked.Marks
+		if len(m.BlackTerritory) != 0 || len(m.WhiteTerritory) != 0 || len(m.Dead) != 0 {
+			t.Errorf("%v: expected no territory or dead stones", v)
+		}
+		if len(m.Atari) != 0 || len(m.Ladders) != 0 || len(m.LadderBreakers) != 0 ||
+			len(m.Nets) != 0 || len(m.Snapbacks) != 0 {
+			t.Errorf("%v: expected no tactics overlay", v)
+		}
+	}
+	if len(frame.Marks.Dead) == 0 {
+		t.Errorf("masking shouldn't change the original marks")
+	}
+}
+
+func TestMaskSetup(t *testing.T) {
+	s, err := backend.FromSGF("(;GM[1]SZ[9]HA[2]AB[cc][gg]AW[ee];W[cg])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	frame := s.GenerateFullFrame(true)
+
+	fields := backend.OneColor.Mask(frame).Metadata.Fields
+	if len(fields["AB"]) != 3 || len(fields["AW"]) != 0 || fields["HA"][0] != "2" {
+		t.Errorf("expected every setup stone to look black, got: %v", fields)
+	}
+	fields = backend.Blind.Mask(frame).Metadata.Fields
+	if len(fields["AB"]) != 0 || len(fields["AW"]) != 0 {
+		t.Errorf("expected no setup stones in a blind frame, got: %v", fields)
+	}
+	if len(frame.Metadata.Fields["AW"]) != 1 || len(s.Root.Fields["AB"]) != 2 {
+		t.Errorf("masking shouldn't change the root fields")
+	}
+}
+
+func TestAtariGoOver(t *testing.T) {
+	room := backend.NewRoom()
+	room.HandleUpdateSettings(&backend.EventJSON{"update_settings", map[string]interface{}{
+		"buffer":   0.0,
+		"size":     9.0,
+		"nickname": "alice",
+		"password": "",
+		"variant":  "atari go",
+	}, 0, "alice"})
+	s, err := backend.FromSGF("(;GM[1]SZ[9];B[ba];W[aa];B[ab])")
+	if err != nil {
+		t.Fatal(err)
+	}
+	room.State = s
+	s.FastForward()
+
+	for _, evt := range []*backend.EventJSON{
+		{"add_stone", []interface{}{4.0, 4.0}, int(backend.White), "alice"},
+		{"place_stone", []interface{}{4.0, 4.0}, int(backend.White), "alice"},
+		{"pass", nil, int(backend.White), "alice"},
+	} {
+		if bcast := room.HandleEvent(evt); bcast.Event != "nop" {
+			t.Errorf("%s: expected the game to be over, got: %s", evt.Event, bcast.Event)
+		}
+	}
+	if len(s.Current.Down) != 0 {
+		t.Errorf("expected no moves after the first capture")
+	}
+}
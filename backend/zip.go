@@ -20,7 +20,26 @@ func IsZipFile(data []byte) bool {
 	return len(data) > 2 && data[0] == 0x50 && data[1] == 0x4b
 }
 
+// NamedFile is an uploaded file (or one out of a zip archive)
+type NamedFile struct {
+	Name string
+	Data []byte
+}
+
 func Decompress(data []byte) ([][]byte, error) {
+	named, err := DecompressFiles(data)
+	if err != nil {
+		return nil, err
+	}
+	files := [][]byte{}
+	for _, f := range named {
+		files = append(files, f.Data)
+	}
+	return files, nil
+}
+
+// DecompressFiles is Decompress, keeping the name of each file
+func DecompressFiles(data []byte) ([]*NamedFile, error) {
 	// create a reader from the byte slice
 	r := bytes.NewReader(data)
 
@@ -30,7 +49,7 @@ func Decompress(data []byte) ([][]byte, error) {
 		return nil, err
 	}
 
-	files := [][]byte{}
+	files := []*NamedFile{}
 	for _, file := range zipReader.File {
 		rc, err := file.Open()
 		if err != nil {
@@ -40,7 +59,7 @@ func Decompress(data []byte) ([][]byte, error) {
 		if err != nil {
 			continue
 		}
-		files = append(files, fData)
+		files = append(files, &NamedFile{file.Name, fData})
 		rc.Close()
 	}
 	return files, nil
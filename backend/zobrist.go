@@ -0,0 +1,138 @@
+/*
+Copyright (c) 2025 Jared Nishikawa
+
+Permission is hereby granted, free of charge, to any person obtaining a copy of this software and associated documentation files (the "Software"), to deal in the Software without restriction, including without limitation the rights to use, copy, modify, merge, publish, distribute, sublicense, and/or sell copies of the Software, and to permit persons to whom the Software is furnished to do so, subject to the following conditions:
+
+The above copyright notice and this permission notice shall be included in all copies or substantial portions of the Software.
+
+THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY, FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM, OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE SOFTWARE.
+*/
+
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+/*
+every (point, color) pair gets a random 64 bit number
+a position's hash is the xor of the numbers for every stone on the board,
+so playing or removing a stone is a single xor, and a node's hash is
+its parent's hash xor'd with everything in its diff
+
+the hash only covers the stones on the board (not whose turn it is),
+so two branches that reach the same position hash the same
+no matter the move order
+*/
+
+// large enough for any coordinate sgf letters can express
+const zobristSize = 52
+
+var zobristTable [2][zobristSize * zobristSize]uint64
+
+func init() {
+	// fixed seed so hashes are stable across restarts
+	r := rand.New(rand.NewSource(0x5a0b))
+	for i := range zobristTable {
+		for j := range zobristTable[i] {
+			zobristTable[i][j] = r.Uint64()
+		}
+	}
+}
+
+func zobrist(c *Coord, col Color) uint64 {
+	if c == nil || c.X < 0 || c.Y < 0 || c.X >= zobristSize || c.Y >= zobristSize {
+		return 0
+	}
+	switch col {
+	case Black:
+		return zobristTable[0][c.Y*zobristSize+c.X]
+	case White:
+		return zobristTable[1][c.Y*zobristSize+c.X]
+	}
+	return 0
+}
+
+// ZobristHash hashes a whole board from scratch
+func ZobristHash(b *Board) uint64 {
+	var h uint64
+	for j, row := range b.Points {
+		for i, col := range row {
+			h ^= zobrist(&Coord{i, j}, col)
+		}
+	}
+	return h
+}
+
+// ComputeHash sets the node's hash from its parent's and its own diff
+func (n *TreeNode) ComputeHash() {
+	var h uint64
+	if n.Up != nil {
+		h = n.Up.Hash
+	}
+	if n.Diff != nil {
+		for _, ss := range n.Diff.Add {
+			for _, c := range ss.Coords {
+				h ^= zobrist(c, ss.Color)
+			}
+		}
+		for _, ss := range n.Diff.Remove {
+			for _, c := range ss.Coords {
+				h ^= zobrist(c, ss.Color)
+			}
+		}
+	}
+	n.Hash = h
+}
+
+// IsAncestorOf reports whether n is on the path from the root to other
+func (n *TreeNode) IsAncestorOf(other *TreeNode) bool {
+	for m := other.Up; m != nil; m = m.Up {
+		if m == n {
+			return true
+		}
+	}
+	return false
+}
+
+// Transpositions finds the other nodes whose position matches the
+// current node's, sorted by index
+// nodes on the same line (like a pass and the node before it) share
+// a position without being transpositions
+func (s *State) Transpositions() []int {
+	indexes := []int{}
+	for index, n := range s.Nodes {
+		if n == s.Current || n.Hash != s.Current.Hash {
+			continue
+		}
+		if n.IsAncestorOf(s.Current) || s.Current.IsAncestorOf(n) {
+			continue
+		}
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	return indexes
+}
+
+// GotoTransposition jumps to another node with the same position
+// if index is -1, it cycles to the next one after the current node
+func (s *State) GotoTransposition(index int) error {
+	transpositions := s.Transpositions()
+	if len(transpositions) == 0 {
+		return fmt.Errorf("no transpositions")
+	}
+	if index == -1 {
+		index = transpositions[0]
+		for _, t := range transpositions {
+			if t > s.Current.Index {
+				index = t
+				break
+			}
+		}
+	} else if i := sort.SearchInts(transpositions, index); i == len(transpositions) || transpositions[i] != index {
+		return fmt.Errorf("node %d is not a transposition", index)
+	}
+	return s.GotoIndex(index)
+}